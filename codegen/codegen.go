@@ -0,0 +1,101 @@
+// Package codegen reads from Go source what gocsv cannot find at run time, to be
+// run from go:generate programs rather than linked in the programs using gocsv.
+package codegen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/acls/gocsv"
+)
+
+// GenerateHeaderComments parses the Go source of the package at pkgPath, an import path
+// or a directory, and returns the doc comments of the fields of the struct typeName by
+// CSV header: the first key of the field tag, or the field name when it has none. The
+// line comment of a field is used when it has no doc comment, and the fields without
+// comments are left out. Go drops comments from compiled code, so this is meant to be
+// run from a go:generate program writing the map as source, to be given at run time to
+// gocsv.Encoder.SetColumnDescriptions, which normalizes the headers like the tag keys.
+// Only the fields declared in typeName are read, not those of the nested or embedded
+// structs.
+func GenerateHeaderComments(pkgPath, typeName string) (map[string]string, error) {
+	dir := pkgPath
+	if info, err := os.Stat(pkgPath); err != nil || !info.IsDir() {
+		pkg, err := build.Import(pkgPath, ".", build.FindOnly)
+		if err != nil {
+			return nil, err
+		}
+		dir = pkg.Dir
+	}
+	notTest := func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, notTest, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					typeSpec := spec.(*ast.TypeSpec)
+					if typeSpec.Name.Name != typeName {
+						continue
+					}
+					structType, ok := typeSpec.Type.(*ast.StructType)
+					if !ok {
+						return nil, fmt.Errorf("%s in %s is not a struct", typeName, dir)
+					}
+					return fieldComments(structType), nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("no type %s in %s", typeName, dir)
+}
+
+// fieldComments returns the comments of the fields of structType by CSV header.
+func fieldComments(structType *ast.StructType) map[string]string {
+	comments := make(map[string]string)
+	for _, field := range structType.Fields.List {
+		comment := strings.TrimSpace(field.Doc.Text())
+		if comment == "" {
+			comment = strings.TrimSpace(field.Comment.Text())
+		}
+		if comment == "" {
+			continue
+		}
+		var tag string
+		if field.Tag != nil {
+			if unquoted, err := strconv.Unquote(field.Tag.Value); err == nil {
+				tag = reflect.StructTag(unquoted).Get(gocsv.TagName)
+			}
+		}
+		tagKey := strings.TrimSpace(strings.Split(tag, gocsv.TagSeparator)[0])
+		if tagKey == "-" {
+			continue
+		}
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			key := tagKey
+			if key == "" {
+				key = name.Name
+			}
+			comments[key] = comment
+		}
+	}
+	return comments
+}
@@ -0,0 +1,48 @@
+package codegen
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/acls/gocsv"
+)
+
+func TestGenerateHeaderComments(t *testing.T) {
+	comments, err := GenerateHeaderComments("testdata/comments", "Order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"id":       "ID is the number of the order.",
+		"customer": "Customer is the email address\nof the customer.",
+		"Total":    "in euros",
+	}
+	if !reflect.DeepEqual(expected, comments) {
+		t.Fatalf("expected %q, got %q", expected, comments)
+	}
+
+	if _, err := GenerateHeaderComments("testdata/comments", "Invoice"); err == nil {
+		t.Fatal("expected an error for a missing type")
+	}
+
+	type order struct {
+		ID     int    `csv:"id"`
+		Status string `csv:"status"`
+	}
+	e, err := gocsv.NewEncoder(gocsv.NewSafeCSVWriter(csv.NewWriter(ioutil.Discard)), order{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SetColumnDescriptions(comments)
+	b := bytes.Buffer{}
+	if err := e.WriteManifest(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), `"description": "ID is the number of the order."`) || strings.Count(b.String(), "description") != 1 {
+		t.Fatalf("unexpected manifest %s", b.String())
+	}
+}
@@ -0,0 +1,14 @@
+package comments
+
+// Order is an order of the shop.
+type Order struct {
+	// ID is the number of the order.
+	ID int `csv:"id"`
+	// Customer is the email address
+	// of the customer.
+	Customer string  `csv:"customer,omitempty"`
+	Total    float64 // in euros
+	Notes    string  `csv:"-"` // not written
+	Status   string  `csv:"status"`
+	secret   string  // unexported
+}
@@ -7,14 +7,19 @@
 package gocsv
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/csv"
 	"fmt"
 	"io"
 	"os"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 )
 
 // FailIfUnmatchedStructTags indicates whether it is considered an error when there is an unmatched
@@ -25,6 +30,14 @@ var FailIfUnmatchedStructTags = false
 // in the csv header.
 var FailIfDoubleHeaderNames = false
 
+// FailIfUnmatchedColumns indicates whether it is considered an error when a csv column
+// is not mapped to any struct field.
+var FailIfUnmatchedColumns = false
+
+// FailIfUnknownMapKeys indicates whether it is considered an error when a map written by
+// MarshalMaps has a key that is not one of the columns.
+var FailIfUnknownMapKeys = false
+
 // ShouldAlignDuplicateHeadersWithStructFieldOrder indicates whether we should align duplicate CSV
 // headers per their alignment in the struct definition.
 var ShouldAlignDuplicateHeadersWithStructFieldOrder = false
@@ -56,6 +69,61 @@ func SetHeaderNormalizer(f Normalizer) {
 	structInfoCache = sync.Map{}
 }
 
+var trimHeaders = false
+
+// SetTrimHeaders sets whether the leading and trailing white space of the CSV headers
+// is removed before they are aliased, normalized and matched, e.g. " First Name ".
+// The data cells are left as is.
+func SetTrimHeaders(b bool) {
+	trimHeaders = b
+}
+
+var headerPrefixStrip = ""
+
+// SetHeaderPrefixStrip sets a prefix removed from the CSV headers starting with it
+// before they are aliased, normalized and matched, e.g. "fld_" to match fld_name with
+// the name tag. The other headers are matched as is. An empty prefix disables it.
+func SetHeaderPrefixStrip(prefix string) {
+	headerPrefixStrip = prefix
+}
+
+// headerAliases maps CSV headers to the struct keys they stand for.
+var headerAliases map[string]string
+
+// SetHeaderAliases sets a mapping of CSV header -> struct key. While decoding, the
+// headers found in the mapping are replaced by their struct key before being
+// normalized and matched against the struct fields. A nil map disables aliasing.
+func SetHeaderAliases(aliases map[string]string) {
+	headerAliases = aliases
+}
+
+// ComputedFieldFunc computes the cell value of a struct field from a whole CSV record.
+// headerIndex maps each normalized header to its column in record.
+type ComputedFieldFunc func(record []string, headerIndex map[string]int) (string, error)
+
+var computedFields = map[string]ComputedFieldFunc{}
+var computedFieldKeys []string // registration order of computedFields
+
+// RegisterComputedField registers a function computing the cell value of the struct
+// field with the structKey tag, while decoding CSV with a header. The value is then
+// converted like any other cell, and takes precedence over a CSV column matching the
+// same field. Registering a nil function removes the computed field.
+func RegisterComputedField(structKey string, f ComputedFieldFunc) {
+	if _, ok := computedFields[structKey]; ok {
+		for i, key := range computedFieldKeys {
+			if key == structKey {
+				computedFieldKeys = append(computedFieldKeys[:i], computedFieldKeys[i+1:]...)
+				break
+			}
+		}
+		delete(computedFields, structKey)
+	}
+	if f != nil {
+		computedFields[structKey] = f
+		computedFieldKeys = append(computedFieldKeys, structKey)
+	}
+}
+
 // --------------------------------------------------------------------------
 // CSVWriter used to format CSV
 
@@ -79,7 +147,49 @@ func SetCSVWriter(csvWriter func(io.Writer) *SafeCSVWriter) {
 	selfCSVWriter = csvWriter
 }
 
-func getCSVWriter(out io.Writer) *SafeCSVWriter {
+var shouldQuote func(col int, value string) bool
+
+// SetShouldQuote sets a function deciding, for each cell, whether it is quoted by the
+// Marshal functions. The delimiter and line terminator of the SafeCSVWriter set with
+// SetCSVWriter are kept. A nil function restores the default quoting.
+func SetShouldQuote(f func(col int, value string) bool) {
+	shouldQuote = f
+}
+
+var trailingNewline = true
+
+// SetTrailingNewline sets whether the Marshal functions end the CSV with the line
+// terminator of the last row, the default, for consumers that reject it when false.
+func SetTrailingNewline(b bool) {
+	trailingNewline = b
+}
+
+func getCSVWriter(out io.Writer) CSVWriter {
+	if !trailingNewline {
+		out = &trailingNewlineTrimmer{out: out}
+	}
+	if quoteChar != '"' {
+		return quoteCharCSVWriter{newCSVWriter(quoteSwapWriter{out})}
+	}
+	return newCSVWriter(out)
+}
+
+// NewCountingCSVWriter returns a CSVWriter formatting CSV like the Marshal functions
+// to out, and the number of bytes it has written to out so far. As the rows are
+// buffered, the count is only complete once the writer is flushed.
+func NewCountingCSVWriter(out io.Writer) (CSVWriter, *int64) {
+	n := new(int64)
+	return getCSVWriter(countingWriter{out: out, n: n}), n
+}
+
+func newCSVWriter(out io.Writer) CSVWriter {
+	if shouldQuote != nil {
+		config := selfCSVWriter(out)
+		writer := NewQuoteFuncCSVWriter(out, shouldQuote)
+		writer.Comma = config.Comma
+		writer.UseCRLF = config.UseCRLF
+		return writer
+	}
 	return selfCSVWriter(out)
 }
 
@@ -107,7 +217,539 @@ func SetCSVReader(csvReader func(io.Reader) CSVReader) {
 }
 
 func getCSVReader(in io.Reader) CSVReader {
-	return selfCSVReader(in)
+	if quoteChar != '"' {
+		return quoteCharCSVReader{newCSVReader(quoteSwapReader{in})}
+	}
+	return newCSVReader(in)
+}
+
+func newCSVReader(in io.Reader) CSVReader {
+	var comma rune
+	if autoDetectDelimiter {
+		buffered := bufio.NewReaderSize(in, sniffSize)
+		comma = sniffDelimiter(buffered)
+		atomic.StoreInt32(&detectedDelimiter, comma)
+		in = buffered
+	}
+	csvReader := selfCSVReader(in)
+	if r, ok := csvReader.(*csv.Reader); ok {
+		if comma != 0 {
+			r.Comma = comma
+		}
+		if headerSearchDepth > 1 {
+			r.FieldsPerRecord = -1 // the lines before the header have their own number of fields
+		}
+		if lazyQuotes {
+			r.LazyQuotes = true
+		}
+	}
+	return csvReader
+}
+
+var lazyQuotes bool
+
+// SetLazyQuotes sets the LazyQuotes option of the csv.Reader parsing the CSV read from
+// an io.Reader, without building the reader with SetCSVReader: a quote may then appear
+// in an unquoted cell, like a"b, and a lone quote in a quoted cell, like "a "b" c", is
+// kept rather than failing the decode. A quoted cell already spans lines; a newline in
+// an unquoted cell cannot be told apart from the end of the row, so a source writing
+// such cells must quote them or be fixed before decoding.
+func SetLazyQuotes(b bool) {
+	lazyQuotes = b
+}
+
+// --------------------------------------------------------------------------
+// Quote character
+
+var quoteChar = '"'
+
+// SetQuoteChar sets the character quoting the cells read and written from an io.Reader
+// or to an io.Writer, e.g. ' for 'a, b',c. A quote within a quoted cell is doubled, like
+// the double quote in a CSV, and the double quote is an ordinary character. The
+// encoding/csv reader and writer only know the double quote, so it is swapped with q in
+// the bytes read or written, and back in the cells. q must be an ASCII character other
+// than the delimiter and the line terminator; other runes restore the double quote.
+func SetQuoteChar(q rune) {
+	if q >= utf8.RuneSelf {
+		q = '"'
+	}
+	quoteChar = q
+}
+
+// --------------------------------------------------------------------------
+// Delimiter detection
+
+var autoDetectDelimiter bool
+var detectedDelimiter int32 // rune
+
+// SetAutoDetectDelimiter sets whether the delimiter of a CSV read from an io.Reader is
+// detected from its first lines, among comma, semicolon, tab and pipe. The delimiter
+// found the same number of times on each line wins, else the most frequent on the first
+// line. It is only applied when the CSV reader set with SetCSVReader is a *csv.Reader.
+func SetAutoDetectDelimiter(b bool) {
+	autoDetectDelimiter = b
+}
+
+// DetectedDelimiter returns the delimiter detected by the last read with
+// SetAutoDetectDelimiter, or 0 when none was detected.
+func DetectedDelimiter() rune {
+	return atomic.LoadInt32(&detectedDelimiter)
+}
+
+// --------------------------------------------------------------------------
+// Integer overflow
+
+// IntOverflowBehavior is what decoding does with an integer that does not fit in
+// its field, like 300 for an int8 or -1 for a uint.
+type IntOverflowBehavior int
+
+const (
+	// IntOverflowError fails the decoding with an ErrIntOverflow, the default.
+	IntOverflowError IntOverflowBehavior = iota
+	// IntOverflowClamp sets the minimum or the maximum of the field type instead.
+	IntOverflowClamp
+	// IntOverflowZero sets 0 instead.
+	IntOverflowZero
+)
+
+var intOverflowBehavior = IntOverflowError
+var intOverflowWarningHandler func(*ConversionError)
+var intOverflowWarningMutex sync.Mutex // the handler is called from several goroutines when decoding in parallel
+
+// SetIntOverflowBehavior sets what decoding does with an integer that overflows its
+// sized int or uint field.
+func SetIntOverflowBehavior(b IntOverflowBehavior) {
+	intOverflowBehavior = b
+}
+
+// SetIntOverflowWarningHandler sets a function called with each value clamped with
+// IntOverflowClamp, whose Err is ErrIntOverflowClamped. The row is decoded anyway.
+// The calls never overlap, even with SetParallelism.
+func SetIntOverflowWarningHandler(f func(*ConversionError)) {
+	intOverflowWarningHandler = f
+}
+
+// --------------------------------------------------------------------------
+// Duplicate headers
+
+// DuplicateHeaderStrategy is what decoding does with a header repeated in the CSV.
+type DuplicateHeaderStrategy int
+
+const (
+	// DuplicateHeaderKeep matches the repeated headers as is, the default: they are
+	// collected by a slice field, aligned with ShouldAlignDuplicateHeadersWithStructFieldOrder
+	// or rejected with FailIfDoubleHeaderNames.
+	DuplicateHeaderKeep DuplicateHeaderStrategy = iota
+	// DuplicateHeaderSuffix renames the second and next occurrences of a header name
+	// to name_2, name_3 and so on before matching, so that fields tagged with these
+	// names get them.
+	DuplicateHeaderSuffix
+)
+
+var duplicateHeaderStrategy = DuplicateHeaderKeep
+
+// SetDuplicateHeaderStrategy sets what decoding does with the repeated headers.
+func SetDuplicateHeaderStrategy(s DuplicateHeaderStrategy) {
+	duplicateHeaderStrategy = s
+}
+
+// --------------------------------------------------------------------------
+// Float format
+
+var floatFormat byte = 'f'
+var floatPrecision = -1
+
+// SetFloatFormat sets the format and the precision floats are encoded with, as taken
+// by strconv.FormatFloat. The default, 'f' with a precision of -1, is the shortest
+// decimal that decodes to the same float, but it spells out the zeros of very large
+// or very small values: 'g' with -1 round trips as well, with an exponent for them.
+func SetFloatFormat(verb byte, prec int) {
+	floatFormat = verb
+	floatPrecision = prec
+}
+
+// --------------------------------------------------------------------------
+// Flexible integers
+
+var flexibleIntParsing = false
+
+// SetFlexibleIntParsing sets whether int and uint fields are decoded from numbers in
+// scientific notation too, like 1e3 or 1.5E2 written by spreadsheets. They are parsed
+// as floats, and are an error when they are not integers, like 1.5e0.
+func SetFlexibleIntParsing(b bool) {
+	flexibleIntParsing = b
+}
+
+// --------------------------------------------------------------------------
+// Accounting numbers
+
+var accountingNumberParsing = false
+var accountingNegatives = false
+
+// SetAccountingNumberParsing sets whether int, uint and float fields are decoded from
+// accounting numbers too: (123.45) is -123.45 and the + of +5 is dropped.
+func SetAccountingNumberParsing(b bool) {
+	accountingNumberParsing = b
+}
+
+// SetAccountingNegatives sets whether negative floats are encoded in parentheses,
+// (123.45) for -123.45, in place of a minus sign.
+func SetAccountingNegatives(b bool) {
+	accountingNegatives = b
+}
+
+// --------------------------------------------------------------------------
+// Currency fields
+
+var currencySymbols = []rune("$€£¥")
+
+// SetCurrencySymbols sets the symbols dropped around the amounts of the numeric fields
+// tagged with currency, e.g. `csv:"price,currency"`, along with the commas grouping
+// their digits, so $1,234.56 decodes to 1234.56. The default symbols are $, €, £ and ¥.
+// A field tagged like `csv:"price,currency:$"` is encoded with the symbol before it.
+func SetCurrencySymbols(symbols []rune) {
+	currencySymbols = symbols
+}
+
+// --------------------------------------------------------------------------
+// Digit grouping
+
+var digitGroupSeparator = ','
+
+// SetDigitGroupSeparator sets the separator of the groups of three digits of the
+// numeric fields tagged with group, e.g. `csv:"population,group"`, which are encoded
+// like 1,234,567 and decoded without the separators. The default is the comma; use
+// '.' or ' ' for the locales writing 1.234.567 or 1 234 567. With '.', the decimal mark
+// of these fields is the comma, like 1.234.567,5, so that floats are read back as is.
+func SetDigitGroupSeparator(sep rune) {
+	digitGroupSeparator = sep
+}
+
+// --------------------------------------------------------------------------
+// Boolean locales
+
+// boolLocale holds the words of a locale registered with RegisterBoolLocale.
+type boolLocale struct {
+	name       string
+	trueWords  []string
+	falseWords []string
+}
+
+var boolLocales = map[string]*boolLocale{}
+var activeBoolLocale *boolLocale
+
+// RegisterBoolLocale registers the words of the locale name for true and for false,
+// e.g. RegisterBoolLocale("fr", []string{"oui", "vrai"}, []string{"non", "faux"}), to
+// be used once set with SetBoolLocale. Registering a name again replaces its words.
+func RegisterBoolLocale(name string, trueWords, falseWords []string) {
+	locale := &boolLocale{name: name, trueWords: trueWords, falseWords: falseWords}
+	boolLocales[name] = locale
+	if activeBoolLocale != nil && activeBoolLocale.name == name {
+		activeBoolLocale = locale
+	}
+}
+
+// SetBoolLocale sets the locale registered with RegisterBoolLocale whose words bool
+// fields are decoded from, ignoring case, and encoded to, with its first words. An
+// empty cell is still false, and any other word an error. The empty name restores the
+// default words: true, false, yes, no and those of strconv.ParseBool.
+func SetBoolLocale(name string) error {
+	if name == "" {
+		activeBoolLocale = nil
+		return nil
+	}
+	locale, ok := boolLocales[name]
+	if !ok {
+		return fmt.Errorf("cannot set the bool locale %s, it is not registered", name)
+	}
+	activeBoolLocale = locale
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// Presence fields
+
+var presenceMarker = "X"
+
+// SetPresenceMarker sets the cell written for true by the bool fields tagged with
+// presence, e.g. `csv:"flagged,presence"`, which are decoded as true from any cell that
+// is not empty and false from an empty one, and written empty when false. The default
+// marker is X; the truestr tag option of a field wins over it.
+func SetPresenceMarker(marker string) {
+	presenceMarker = marker
+}
+
+// --------------------------------------------------------------------------
+// Percent fields
+
+var percentAsRawNumber = false
+
+// SetPercentAsRawNumber sets whether a float field tagged with percent, e.g.
+// `csv:"rate,percent"`, holds the number written before the % sign: 12.5 for 12.5%.
+// The default is false, the field holds the fraction: 0.125 for 12.5%.
+func SetPercentAsRawNumber(b bool) {
+	percentAsRawNumber = b
+}
+
+// --------------------------------------------------------------------------
+// JSON fields
+
+var emptyJSONArrayAsEmptySlice bool
+var emptyJSONAsNil bool
+
+// SetEmptyJSONArrayAsEmptySlice sets whether an empty cell of a field tagged with
+// jsonarray, e.g. `csv:"tags,jsonarray"`, is decoded as an empty slice. The default
+// is false, which decodes it as a nil slice.
+func SetEmptyJSONArrayAsEmptySlice(b bool) {
+	emptyJSONArrayAsEmptySlice = b
+}
+
+// SetEmptyJSONAsNil sets whether an empty cell of a pointer field tagged with json,
+// e.g. `csv:"profile,json"`, is decoded as a nil pointer. The default is false, which
+// decodes it as a pointer to the zero value, like the zero struct of a struct field.
+func SetEmptyJSONAsNil(b bool) {
+	emptyJSONAsNil = b
+}
+
+// --------------------------------------------------------------------------
+// Invalid UTF-8
+
+var rejectInvalidUTF8 bool
+
+// SetRejectInvalidUTF8 sets whether decoding fails on a cell that is not valid UTF-8,
+// with a *ConversionError wrapping ErrInvalidUTF8 naming the column. Every cell of
+// the row is checked, mapped to a field or not. The default is false.
+func SetRejectInvalidUTF8(b bool) {
+	rejectInvalidUTF8 = b
+}
+
+// --------------------------------------------------------------------------
+// Field type checks
+
+var checkFieldTypes = false
+
+// SetCheckFieldTypes sets whether NewEncoder and NewUnmarshaller return an
+// ErrUnsupportedFieldType naming the fields whose type has no conversion to or from a
+// cell, like a chan, rather than writing them as empty cells or failing at the first
+// row. A field converted with SetFieldEncoder or SetFieldDecoder, set once the Encoder
+// or the Unmarshaller is created, must then have a supported type too.
+func SetCheckFieldTypes(b bool) {
+	checkFieldTypes = b
+}
+
+// --------------------------------------------------------------------------
+// Nesting depth
+
+const defaultMaxNestingDepth = 64
+
+var maxNestingDepth = defaultMaxNestingDepth
+
+// SetMaxNestingDepth sets how many levels of nested structs, through struct, pointer
+// and slice fields, a type may have, 64 by default, so that a type nesting itself, like
+// a Children []Node field of Node, fails with ErrMaxNestingDepthExceeded rather than
+// overflowing the stack. n < 1 restores the default.
+func SetMaxNestingDepth(n int) {
+	if n < 1 {
+		n = defaultMaxNestingDepth
+	}
+	maxNestingDepth = n
+}
+
+// --------------------------------------------------------------------------
+// Time location
+
+var timeLocation *time.Location
+
+// SetTimeLocation sets the location time.Time values are converted to before being
+// encoded, so every row uses the same zone whatever the source of the value. When
+// decoding, times are converted to loc as well, and a time without a zone, such as
+// 2006-01-02T15:04:05, is read in loc. A nil loc keeps the location of each value,
+// which is the default.
+func SetTimeLocation(loc *time.Location) {
+	timeLocation = loc
+}
+
+var timeLayouts []string
+
+// SetTimeLayouts sets the layouts, as taken by time.Parse, tried in order to decode a
+// time.Time until one matches, e.g. for a column whose date format changes between
+// rows. A time without a zone is read in the location set with SetTimeLocation, else
+// in UTC. No layouts, the default, decode RFC 3339 times. Encoding is not affected.
+func SetTimeLayouts(layouts []string) {
+	timeLayouts = layouts
+}
+
+var zeroTimeAsEmpty bool
+
+// SetZeroTimeAsEmpty sets whether the zero time.Time, directly or through a pointer, is
+// encoded as an empty cell rather than 0001-01-01T00:00:00Z, and an empty cell decoded
+// to the zero time, whatever the layouts set with SetTimeLayouts.
+func SetZeroTimeAsEmpty(b bool) {
+	zeroTimeAsEmpty = b
+}
+
+// --------------------------------------------------------------------------
+// Ignored columns
+
+var ignoredColumns []string
+
+// SetIgnoredColumns sets the CSV headers whose columns are skipped when decoding:
+// they are not mapped to any struct field, even one with a matching tag, and they
+// are not reported by FailIfUnmatchedColumns. Calling it again replaces the list.
+func SetIgnoredColumns(headers ...string) {
+	ignoredColumns = headers
+}
+
+// --------------------------------------------------------------------------
+// Unmatched columns
+
+var unmatchedColumnHandler func(header string)
+
+// SetUnmatchedColumnHandler sets a function called with the header of each column that
+// matches no struct field, once the header line is read, e.g. to log schema drift. The
+// decode goes on, unless FailIfUnmatchedColumns is set too. A nil function, the
+// default, removes the handler.
+func SetUnmatchedColumnHandler(f func(header string)) {
+	unmatchedColumnHandler = f
+}
+
+// --------------------------------------------------------------------------
+// Header search
+
+var headerSearchDepth = 1
+var headerMinMatches = 1
+
+// SetHeaderSearchDepth sets the number of first lines searched for the header when
+// decoding with headers. The line whose cells match the most struct fields is the
+// header, the lines before it, e.g. a title or a date, are skipped. Decoding fails
+// when no line matches the minimum set with SetHeaderMinMatches. As these lines have
+// their own number of fields, the *csv.Reader reading from an io.Reader does not
+// check the number of fields per record while the search is enabled.
+// A value of 1 or less disables the search: the first line is the header, which is
+// the default.
+func SetHeaderSearchDepth(n int) {
+	headerSearchDepth = n
+}
+
+// SetHeaderMinMatches sets the minimum number of struct fields a line must match to
+// be found as the header by SetHeaderSearchDepth. The default is 1.
+func SetHeaderMinMatches(n int) {
+	headerMinMatches = n
+}
+
+// --------------------------------------------------------------------------
+// Decode limits
+
+var maxRows int
+var maxBytes int64
+var maxFieldLength int
+
+// SetMaxRows sets the maximum number of CSV records, the header included, read
+// while decoding. Decoding stops with ErrMaxRowsExceeded as soon as the limit
+// is exceeded. A value of 0 or less disables the limit.
+func SetMaxRows(n int) {
+	maxRows = n
+}
+
+// SetMaxBytes sets the maximum number of bytes read from an io.Reader while
+// decoding. Decoding stops with ErrMaxBytesExceeded as soon as the limit is
+// exceeded. A value of 0 or less disables the limit.
+func SetMaxBytes(n int64) {
+	maxBytes = n
+}
+
+// SetMaxFieldLength sets the maximum length in bytes of a cell assigned to a struct
+// field, custom unmarshallers included. A longer cell fails the row with a
+// *ConversionError wrapping ErrMaxFieldLengthExceeded instead of being stored.
+// A value of 0 or less disables the limit.
+func SetMaxFieldLength(n int) {
+	maxFieldLength = n
+}
+
+// --------------------------------------------------------------------------
+// Empty rows
+
+var nilForEmptyRows = false
+
+// SetNilForEmptyRows sets whether rows where every cell is empty are decoded as
+// nil pointers, when the output holds pointers to structs (e.g. *[]*T). A row
+// with at least one non-empty cell always allocates a struct.
+func SetNilForEmptyRows(b bool) {
+	nilForEmptyRows = b
+}
+
+var skipEmptyRows = false
+
+// SetSkipEmptyRows sets whether the blank rows at the end of the CSV are skipped while
+// decoding. A row is blank when every cell is empty or only holds white space, such
+// as a last line of commas or spaces. Only trailing blank rows are considered noise:
+// a blank row followed by a non blank one is a genuine empty record and is decoded.
+func SetSkipEmptyRows(b bool) {
+	skipEmptyRows = b
+}
+
+// --------------------------------------------------------------------------
+// Empty values
+
+var emptyValues []string
+var emptyValuesIgnoreCase = false
+
+// SetEmptyValues sets the cells decoded as if they were empty, whatever the type of
+// their field, e.g. NULL, N/A or -: the field then gets its default value, or remains
+// a nil pointer with omitempty, or the zero value. They must match the whole cell, and
+// match its case unless set with SetEmptyValuesIgnoreCase.
+func SetEmptyValues(values []string) {
+	emptyValues = values
+}
+
+// SetEmptyValuesIgnoreCase sets whether the cells set with SetEmptyValues match them
+// ignoring case, so that null and Null are empty like NULL.
+func SetEmptyValuesIgnoreCase(b bool) {
+	emptyValuesIgnoreCase = b
+}
+
+// --------------------------------------------------------------------------
+// Partial results
+
+var partialResults = false
+
+// SetPartialResults sets whether Unmarshal and the functions decoding in a slice leave
+// in it the rows decoded before the first row that fails, when they return its error,
+// so that they can be inspected or kept. The slice then holds as many elements as rows
+// before the failing one. The default is false: the content of the slice is then
+// unspecified after an error, as it was before this option.
+func SetPartialResults(b bool) {
+	partialResults = b
+}
+
+// --------------------------------------------------------------------------
+// Parallel decoding
+
+var parallelism = 1
+
+// SetParallelism sets the number of goroutines converting the CSV rows to structs
+// when unmarshalling into a slice or an array. The CSV is still read by a single
+// goroutine, and the rows keep their order in the output. When n is greater than 1,
+// the custom unmarshallers, the functions set with SetFieldDecoder and those
+// registered with RegisterComputedField are called concurrently, and must be safe
+// for concurrent use.
+// A value of 1 or less decodes sequentially, which is the default.
+func SetParallelism(n int) {
+	parallelism = n
+}
+
+// --------------------------------------------------------------------------
+// Panics
+
+var recoverFromPanics bool
+
+// SetRecoverFromPanics sets whether a panic in a custom TypeUnmarshaller, TypeMarshaller,
+// TypeUnmarshalCSVWithFields, MultiFieldMarshaller or text (un)marshaller, or in a
+// function set with SetFieldEncoder, SetFieldDecoder, SetPreWriteHook or
+// RegisterComputedField, is recovered and returned as the error of that cell or row,
+// like any conversion error, instead of crashing the program. The default is false.
+func SetRecoverFromPanics(b bool) {
+	recoverFromPanics = b
 }
 
 // --------------------------------------------------------------------------
@@ -143,11 +785,28 @@ func Marshal(in interface{}, out io.Writer) (err error) {
 }
 
 // MarshalWithoutHeaders returns the CSV in writer from the interface.
+// The columns are in the order of the header written by WriteHeaderFor for the same
+// struct type, so fragments written by several workers can be concatenated under it.
 func MarshalWithoutHeaders(in interface{}, out io.Writer) (err error) {
 	writer := getCSVWriter(out)
 	return writeTo(writer, in, true)
 }
 
+// WriteHeaderFor writes in writer the header of the CSV returned by Marshal for the
+// type of sample, a struct or a pointer to a struct, and nothing else. Rows written
+// with MarshalWithoutHeaders for that type can follow it, as long as each fragment
+// ends with its line terminator, see SetTrailingNewline.
+func WriteHeaderFor(sample interface{}, out io.Writer) error {
+	e, err := NewEncoder(getCSVWriter(out), sample)
+	if err != nil {
+		return err
+	}
+	if err := e.WriteHeader(); err != nil {
+		return err
+	}
+	return e.Flush()
+}
+
 // MarshalChan returns the CSV read from the channel.
 func MarshalChan(c <-chan interface{}, out CSVWriter) error {
 	return writeFromChan(out, c, false)
@@ -168,6 +827,77 @@ func MarshalCSVWithoutHeaders(in interface{}, out CSVWriter) (err error) {
 	return writeTo(out, in, true)
 }
 
+// MarshalTSV writes the tab-separated values in writer from the interface. A cell
+// holding a tab, a new line or a double quote is still quoted like in a CSV, as
+// there is no other way to write it.
+func MarshalTSV(in interface{}, out io.Writer) (err error) {
+	writer := NewSafeCSVWriter(csv.NewWriter(out))
+	writer.Comma = '\t'
+	return writeTo(writer, in, false)
+}
+
+// MarshalEncoding returns the CSV in writer from the interface, converted from UTF-8
+// by the writer that encode wraps around it, e.g. the Writer method of an Encoder of
+// golang.org/x/text/encoding: charmap.Windows1252.NewEncoder().Writer. It takes such a
+// function rather than an encoding.Encoding so that gocsv does not depend on
+// golang.org/x/text. The encoder decides what to do with a rune it cannot map, it
+// fails by default, and encoding.ReplaceUnsupported makes it write a replacement
+// instead. The wrapping writer is closed, to flush it, if it is an io.Closer, even
+// when the CSV cannot be written.
+func MarshalEncoding(in interface{}, out io.Writer, encode func(io.Writer) io.Writer) error {
+	encoded := encode(out)
+	err := Marshal(in, encoded)
+	if closer, ok := encoded.(io.Closer); ok {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// MarshalZip writes a zip archive in writer holding one CSV file per slice of structs
+// field of the struct in. Each file is named after the first key of the field tag,
+// e.g. `csv:"users"` is written as users.csv.
+func MarshalZip(in interface{}, out io.Writer) (err error) {
+	return writeZip(out, in)
+}
+
+// MarshalDropEmptyColumns writes in like MarshalCSV, without the columns that are nil
+// in every row: nil pointers, interfaces, slices or maps, or fields reached through
+// a nil pointer. in is scanned twice, once to find these columns, then to write it.
+// An empty in is written with all the columns of MarshalCSV.
+func MarshalDropEmptyColumns(in interface{}, writer CSVWriter) error {
+	return writeDropEmptyColumns(writer, in)
+}
+
+// MarshalSorted writes in, a slice or an array of structs, in the order given by
+// less, which is called with two of its elements. A sorted copy of in is written,
+// in itself is left as is. Elements that are equal keep their order.
+func MarshalSorted(in interface{}, writer CSVWriter, less func(i, j interface{}) bool) error {
+	sorted, err := sortedCopy(in, less)
+	if err != nil {
+		return err
+	}
+	return writeTo(writer, sorted, false)
+}
+
+// MarshalDedup writes in, a slice or an array of structs, skipping the rows whose
+// cells in keyColumns, or in every column when keyColumns is empty, are those of an
+// earlier row, so that the first of duplicate rows is kept. The keys of every row
+// written are kept in memory until in is written, which is as much as the cells of
+// the key columns of in.
+func MarshalDedup(in interface{}, writer CSVWriter, keyColumns []string) error {
+	return writeTo(&dedupCSVWriter{CSVWriter: writer, keyColumns: keyColumns}, in, false)
+}
+
+// MarshalPretty writes in, a slice or an array of structs, with each cell padded with
+// spaces to the width of its column, so that the columns line up in a terminal, e.g.
+// for debugging. The output is not meant to be parsed back. Every row is held in
+// memory to find the widths before the first one is written.
+func MarshalPretty(in interface{}, out io.Writer) error {
+	return writePretty(out, in)
+}
+
 // --------------------------------------------------------------------------
 // Unmarshal functions
 
@@ -201,6 +931,57 @@ func UnmarshalWithErrorHandler(in io.Reader, errHandle ErrorHandler, out interfa
 	return readToWithErrorHandler(newSimpleDecoderFromReader(in), errHandle, out)
 }
 
+// UnmarshalWithCoercionReport parses the CSV from the reader in the interface like
+// UnmarshalWithErrorHandler, and returns how the cells of each column were converted,
+// e.g. to judge the quality of the data before importing it. errHandle may be nil.
+func UnmarshalWithCoercionReport(in io.Reader, errHandle ErrorHandler, out interface{}) (*CoercionReport, error) {
+	report := newCoercionReport()
+	if err := readToWithCoercionReport(newSimpleDecoderFromReader(in), errHandle, out, report, false); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// UnmarshalInto parses the CSV from the reader in the existing elements of the slice or
+// array out, row i in element i, without allocating new structs: only the fields
+// mapped to a column are overwritten, the others keep their value. out must have as
+// many elements as the CSV has rows, or an error is returned.
+func UnmarshalInto(in io.Reader, out interface{}) error {
+	return readToWithCoercionReport(newSimpleDecoderFromReader(in), nil, out, nil, true)
+}
+
+// UnmarshalEncoding parses the CSV from the reader in the interface, converted to UTF-8
+// by the reader that decode wraps around it, e.g. the Reader method of a Decoder of
+// golang.org/x/text/encoding: charmap.ISO8859_1.NewDecoder().Reader, taken rather than an
+// encoding.Encoding like for MarshalEncoding.
+func UnmarshalEncoding(in io.Reader, out interface{}, decode func(io.Reader) io.Reader) error {
+	return Unmarshal(decode(in), out)
+}
+
+// UnmarshalPreview parses at most the n first rows of the CSV from the reader in the interface.
+// Reading stops after these rows, so only the beginning of a large CSV is parsed.
+func UnmarshalPreview(in io.Reader, out interface{}, n int) error {
+	return readTo(previewDecoder{newSimpleDecoderFromReader(in), n + 1}, out) // n rows and the header
+}
+
+// UnmarshalAppend parses the CSV from the reader and appends the rows to the slice
+// pointed to by out, e.g. to gather several files in one slice. Nothing is appended
+// when an error is returned.
+func UnmarshalAppend(in io.Reader, out interface{}) error {
+	return readToAppend(newSimpleDecoderFromReader(in), out)
+}
+
+// UnmarshalGrouped parses the CSV from the reader in out, a pointer to a slice of
+// structs with a slice of struct field, grouping the rows by the value of keyColumn:
+// each group gives one struct, decoded from the first row of the group, whose slice
+// of struct fields get one element per row of the group. Only contiguous rows are
+// grouped, a key found again after another one starts a new group, so the CSV must
+// be sorted by keyColumn. The parent and the children are decoded from the same
+// columns, each taking the ones matching its own tags.
+func UnmarshalGrouped(in io.Reader, out interface{}, keyColumn string) error {
+	return readToGrouped(newSimpleDecoderFromReader(in), out, keyColumn)
+}
+
 // UnmarshalWithoutHeaders parses the CSV from the reader in the interface.
 func UnmarshalWithoutHeaders(in io.Reader, out interface{}) error {
 	return readToWithoutHeaders(newSimpleDecoderFromReader(in), out)
@@ -208,7 +989,7 @@ func UnmarshalWithoutHeaders(in io.Reader, out interface{}) error {
 
 // UnmarshalCSVWithoutHeaders parses a headerless CSV with passed in CSV reader
 func UnmarshalCSVWithoutHeaders(in CSVReader, out interface{}) error {
-	return readToWithoutHeaders(csvDecoder{in}, out)
+	return readToWithoutHeaders(newCSVDecoder(in), out)
 }
 
 // UnmarshalDecoder parses the CSV from the decoder in the interface
@@ -218,7 +999,18 @@ func UnmarshalDecoder(in Decoder, out interface{}) error {
 
 // UnmarshalCSV parses the CSV from the reader in the interface.
 func UnmarshalCSV(in CSVReader, out interface{}) error {
-	return readTo(csvDecoder{in}, out)
+	return readTo(newCSVDecoder(in), out)
+}
+
+// UnmarshalTSV parses the tab-separated values from the reader in the interface.
+// TSV cells are seldom quoted, so double quotes are read with LazyQuotes: a quote
+// within a cell is kept as is, while a cell starting with one is still read as a
+// quoted CSV cell. The CSV reader set with SetCSVReader is not used.
+func UnmarshalTSV(in io.Reader, out interface{}) error {
+	reader := csv.NewReader(in)
+	reader.Comma = '\t'
+	reader.LazyQuotes = true
+	return readTo(newCSVDecoder(reader), out)
 }
 
 // UnmarshalCSVToMap parses a CSV of 2 columns into a map.
@@ -365,6 +1157,58 @@ func UnmarshalStringToCallback(in string, c interface{}) (err error) {
 	return UnmarshalToCallback(strings.NewReader(in), c)
 }
 
+// UnmarshalToCallbackWithMask parses the CSV from the reader and sends each value to
+// the given func f, with whether each field was set from a non-empty cell, to tell an
+// empty cell from a cell holding the zero value. populated[i] is for the ith column
+// written by Marshal for the struct; computed fields are never reported as populated.
+//
+// The func must look like func(Struct, []bool).
+func UnmarshalToCallbackWithMask(in io.Reader, f interface{}) error {
+	valueFunc := reflect.ValueOf(f)
+	t := reflect.TypeOf(f)
+	if t == nil || t.Kind() != reflect.Func || t.NumIn() != 2 || t.In(1) != reflect.TypeOf([]bool(nil)) {
+		return fmt.Errorf("the given function must look like func(Struct, []bool)")
+	}
+	return readEachFunc(newSimpleDecoderFromReader(in), t.In(0), true, func(v reflect.Value, populated []bool) error {
+		valueFunc.Call([]reflect.Value{v, reflect.ValueOf(populated)})
+		return nil
+	})
+}
+
+// Transform parses the CSV from the reader row by row, into a value of the type of
+// sample, a struct or a pointer to a struct, and writes in writer the value f returns
+// for it, skipping the row when it is nil. The values returned must all be of the same
+// struct type, whose header is written before the first of them, so nothing is
+// written when they are all nil. Only one row is held in memory at a time.
+// Transforming stops at the first error returned by f.
+func Transform(in io.Reader, writer CSVWriter, sample interface{}, f func(v interface{}) (interface{}, error)) error {
+	t := reflect.TypeOf(sample)
+	if t == nil {
+		return fmt.Errorf("cannot use nil sample, only struct supported")
+	}
+	var e *Encoder
+	err := readEachFunc(newSimpleDecoderFromReader(in), t, false, func(v reflect.Value, _ []bool) error {
+		out, err := f(v.Interface())
+		if err != nil || out == nil {
+			return err
+		}
+		if e == nil {
+			if e, err = NewEncoder(writer, out); err != nil {
+				return err
+			}
+			if err := e.WriteHeader(); err != nil {
+				return err
+			}
+		}
+		return e.Encode(out)
+	})
+	if err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
 // UnmarshalToCallbackWithError parses the CSV from the reader and
 // send each value to the given func f.
 //
@@ -496,6 +1340,43 @@ func CSVToMaps(reader io.Reader) ([]map[string]string, error) {
 	return rows, nil
 }
 
+// MarshalMaps writes the columns as the header, then a row per map of rows with its
+// values in the order of the columns, and an empty cell for a missing key, so that the
+// maps returned by CSVToMaps are written back with their header. The keys that are not
+// one of the columns are left out, or are an error when FailIfUnknownMapKeys is set.
+func MarshalMaps(rows []map[string]string, columns []string, writer CSVWriter) error {
+	known := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		known[column] = true
+	}
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+	record := make([]string, len(columns))
+	for i, row := range rows {
+		if FailIfUnknownMapKeys {
+			var unknown []string
+			for key := range row {
+				if !known[key] {
+					unknown = append(unknown, key)
+				}
+			}
+			if len(unknown) > 0 {
+				sort.Strings(unknown)
+				return fmt.Errorf("map %d has the keys %q that are not columns", i, unknown)
+			}
+		}
+		for j, column := range columns {
+			record[j] = row[column]
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
 // CSVToChanMaps parses the CSV from the reader and send a dictionary in the chan c, using the header row as the keys.
 func CSVToChanMaps(reader io.Reader, c chan<- map[string]string) error {
 	r := csv.NewReader(reader)
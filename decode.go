@@ -1,11 +1,16 @@
 package gocsv
 
 import (
+	"bufio"
 	"encoding/csv"
 	"errors"
 	"fmt"
 	"io"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
 )
 
 // Decoder .
@@ -29,20 +34,350 @@ type csvDecoder struct {
 }
 
 func newSimpleDecoderFromReader(r io.Reader) SimpleDecoder {
-	return csvDecoder{getCSVReader(r)}
+	if maxBytes > 0 {
+		r = &maxBytesReader{r: r, remaining: maxBytes}
+	}
+	return newCSVDecoder(getCSVReader(r))
+}
+
+func newCSVDecoder(r CSVReader) csvDecoder {
+	if maxRows > 0 {
+		r = &maxRowsCSVReader{CSVReader: r, remaining: maxRows}
+	}
+	if skipEmptyRows {
+		r = &trailingEmptyRowsCSVReader{CSVReader: r}
+	}
+	return csvDecoder{r}
 }
 
 var (
-	ErrEmptyCSVFile = errors.New("empty csv file given")
-	ErrNoStructTags = errors.New("no csv struct tags found")
+	ErrEmptyCSVFile     = errors.New("empty csv file given")
+	ErrNoStructTags     = errors.New("no csv struct tags found")
+	ErrMaxRowsExceeded  = errors.New("csv exceeds the maximum number of rows")
+	ErrMaxBytesExceeded = errors.New("csv exceeds the maximum number of bytes")
+	ErrInvalidUTF8      = errors.New("invalid UTF-8")
+
+	ErrMaxFieldLengthExceeded = errors.New("csv field exceeds the maximum length")
+	ErrIntOverflow            = errors.New("integer overflows its field")
+	ErrIntOverflowClamped     = errors.New("integer clamped to the range of its field")
+	ErrUnsupportedFieldType   = errors.New("no conversion for the type of the field")
+
+	ErrMaxNestingDepthExceeded = errors.New("struct exceeds the maximum nesting depth")
 )
 
+const sniffSize = 64 * 1024 // bytes peeked to detect the delimiter
+const sniffLines = 5
+
+var delimiterCandidates = []rune{',', ';', '\t', '|'}
+
+// sniffDelimiter returns the delimiter of the first lines buffered in r, without
+// consuming them, or 0 when no candidate is found.
+func sniffDelimiter(r *bufio.Reader) rune {
+	peek, err := r.Peek(sniffSize)
+	lines := strings.Split(string(peek), "\n")
+	if err == nil && len(lines) > 1 {
+		lines = lines[:len(lines)-1] // the last line may be cut
+	}
+	var sample []string
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			sample = append(sample, line)
+		}
+		if len(sample) == sniffLines {
+			break
+		}
+	}
+	if len(sample) == 0 {
+		return 0
+	}
+	var best rune
+	bestCount, bestConsistent := 0, false
+	for _, c := range delimiterCandidates {
+		first := countUnquoted(sample[0], c)
+		if first == 0 {
+			continue
+		}
+		consistent := true
+		for _, line := range sample[1:] {
+			if countUnquoted(line, c) != first {
+				consistent = false
+				break
+			}
+		}
+		if (consistent && !bestConsistent) || (consistent == bestConsistent && first > bestCount) {
+			best, bestCount, bestConsistent = c, first, consistent
+		}
+	}
+	return best
+}
+
+// countUnquoted counts c in line, outside of double quoted fields
+func countUnquoted(line string, c rune) int {
+	n := 0
+	quoted := false
+	for _, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == c && !quoted {
+			n++
+		}
+	}
+	return n
+}
+
+// maxBytesReader fails with ErrMaxBytesExceeded as soon as more than
+// remaining bytes are read from r.
+type maxBytesReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (r *maxBytesReader) Read(p []byte) (int, error) {
+	if r.remaining < 0 {
+		return 0, ErrMaxBytesExceeded
+	}
+	// Read at most one byte past the limit, it is enough to detect the overflow.
+	if int64(len(p)) > r.remaining+1 {
+		p = p[:r.remaining+1]
+	}
+	n, err := r.r.Read(p)
+	if int64(n) > r.remaining {
+		n = int(r.remaining)
+		r.remaining = -1
+		return n, ErrMaxBytesExceeded
+	}
+	r.remaining -= int64(n)
+	return n, err
+}
+
+// maxRowsCSVReader fails with ErrMaxRowsExceeded when more than remaining
+// records are read.
+type maxRowsCSVReader struct {
+	CSVReader
+	remaining int
+}
+
+func (r *maxRowsCSVReader) Read() ([]string, error) {
+	record, err := r.CSVReader.Read()
+	if err != nil {
+		return record, err
+	}
+	if r.remaining <= 0 {
+		return nil, ErrMaxRowsExceeded
+	}
+	r.remaining--
+	return record, nil
+}
+
+// ReadAll reads the records one at a time so it stops as soon as the limit is reached.
+func (r *maxRowsCSVReader) ReadAll() ([][]string, error) {
+	return readAll(r)
+}
+
+// trailingEmptyRowsCSVReader drops the blank records found at the end of the input.
+// Blank records followed by a non blank one, or by a read error, are returned as they are.
+type trailingEmptyRowsCSVReader struct {
+	CSVReader
+	pending [][]string // buffered records to return before reading again
+	err     error      // returned after the pending records
+}
+
+func (r *trailingEmptyRowsCSVReader) Read() ([]string, error) {
+	if len(r.pending) > 0 {
+		record := r.pending[0]
+		r.pending = r.pending[1:]
+		return record, nil
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	var blanks [][]string
+	for {
+		record, err := r.CSVReader.Read()
+		if err == io.EOF {
+			return nil, err // the buffered blank records were trailing
+		} else if err != nil {
+			if len(blanks) == 0 {
+				return nil, err
+			}
+			r.pending, r.err = blanks[1:], err
+			return blanks[0], nil
+		}
+		trimmed := make([]string, len(record))
+		for i, cell := range record {
+			trimmed[i] = strings.TrimSpace(cell)
+		}
+		if !isEmptyRow(trimmed) {
+			if len(blanks) == 0 {
+				return record, nil
+			}
+			r.pending = append(blanks[1:], record)
+			return blanks[0], nil
+		}
+		blanks = append(blanks, record)
+	}
+}
+
+func (r *trailingEmptyRowsCSVReader) ReadAll() ([][]string, error) {
+	return readAll(r)
+}
+
+func readAll(r CSVReader) ([][]string, error) {
+	records := [][]string{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
+
 // NewSimpleDecoderFromCSVReader creates a SimpleDecoder, which may be passed
 // to the UnmarshalDecoder* family of functions, from a CSV reader. Note that
 // encoding/csv.Reader implements CSVReader, so you can pass one of those
 // directly here.
 func NewSimpleDecoderFromCSVReader(r CSVReader) SimpleDecoder {
-	return csvDecoder{r}
+	return newCSVDecoder(r)
+}
+
+// NewSimpleDecoderWithHeaders creates a SimpleDecoder from a CSV reader with no
+// header line. The given headers are used to map the columns to the struct
+// fields, and every record of the reader is decoded as data.
+func NewSimpleDecoderWithHeaders(headers []string, r CSVReader) SimpleDecoder {
+	return &headersDecoder{headers: headers, SimpleDecoder: newCSVDecoder(r)}
+}
+
+// headersDecoder returns its headers before the records of the wrapped decoder.
+type headersDecoder struct {
+	SimpleDecoder
+	headers []string
+	done    bool
+}
+
+func (d *headersDecoder) GetCSVRow() ([]string, error) {
+	if !d.done {
+		d.done = true
+		return append([]string(nil), d.headers...), nil
+	}
+	return d.SimpleDecoder.GetCSVRow()
+}
+
+func (d *headersDecoder) GetCSVRows() ([][]string, error) {
+	rows, err := d.SimpleDecoder.GetCSVRows()
+	if err != nil {
+		return nil, err
+	}
+	if !d.done {
+		d.done = true
+		rows = append([][]string{append([]string(nil), d.headers...)}, rows...)
+	}
+	return rows, nil
+}
+
+// NewColumnValueDecoder creates a SimpleDecoder from column names and a function
+// returning the next row of values, and false once there are no more rows. Any
+// row-by-row source, such as a thin wrapper over sql.Rows, can then be unmarshalled
+// without building the whole [][]string first.
+func NewColumnValueDecoder(columns []string, next func() ([]string, bool)) SimpleDecoder {
+	return &columnValueDecoder{columns: columns, next: next}
+}
+
+type columnValueDecoder struct {
+	columns []string
+	next    func() ([]string, bool)
+	started bool
+}
+
+func (d *columnValueDecoder) GetCSVRow() ([]string, error) {
+	if !d.started {
+		d.started = true
+		return append([]string(nil), d.columns...), nil
+	}
+	row, ok := d.next()
+	if !ok {
+		return nil, io.EOF
+	}
+	return row, nil
+}
+
+func (d *columnValueDecoder) GetCSVRows() ([][]string, error) {
+	rows := [][]string{}
+	for {
+		row, err := d.GetCSVRow()
+		if err == io.EOF {
+			return rows, nil
+		}
+		rows = append(rows, row)
+	}
+}
+
+// previewDecoder returns the rows of the wrapped decoder, up to max rows.
+type previewDecoder struct {
+	SimpleDecoder
+	max int
+}
+
+func (d previewDecoder) GetCSVRows() ([][]string, error) {
+	rows := [][]string{}
+	for len(rows) < d.max {
+		row, err := d.GetCSVRow()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// prependRowsDecoder returns rows, then the rows of the wrapped decoder
+type prependRowsDecoder struct {
+	SimpleDecoder
+	rows [][]string
+}
+
+func (d *prependRowsDecoder) GetCSVRow() ([]string, error) {
+	if len(d.rows) > 0 {
+		row := d.rows[0]
+		d.rows = d.rows[1:]
+		return row, nil
+	}
+	return d.SimpleDecoder.GetCSVRow()
+}
+
+// findHeaderRow returns the index of the row of rows, among the first set with
+// SetHeaderSearchDepth, whose cells match the most struct fields.
+func findHeaderRow(rows [][]string, structInfo *structInfo) (int, error) {
+	if headerSearchDepth <= 1 {
+		return 0, nil
+	}
+	best, bestMatches := 0, 0
+	for r := 0; r < len(rows) && r < headerSearchDepth; r++ {
+		matches := 0
+		for _, header := range normalizeHeaders(rows[r]) {
+			if getCSVFieldPosition(header, structInfo, 0) != nil {
+				matches++
+			}
+		}
+		if matches > bestMatches {
+			best, bestMatches = r, matches
+		}
+	}
+	if bestMatches < headerMinMatches {
+		return 0, fmt.Errorf("no csv header found in the first %d lines: the best line matches %d struct fields, %d needed", headerSearchDepth, bestMatches, headerMinMatches)
+	}
+	return best, nil
+}
+
+// rowsDecoder returns rows already read
+type rowsDecoder [][]string
+
+func (d rowsDecoder) GetCSVRows() ([][]string, error) {
+	return d, nil
 }
 
 func (c csvDecoder) GetCSVRows() ([][]string, error) {
@@ -65,6 +400,9 @@ func mismatchStructFields(structInfo []fieldInfo, headers []string) []string {
 	}
 
 	for _, info := range structInfo {
+		if info.multiColumn || (info.indexed && info.columnIndex < len(headers)) {
+			continue
+		}
 		found := false
 		for _, key := range info.keys {
 			if _, ok := headerMap[key]; ok {
@@ -93,7 +431,7 @@ func mismatchHeaderFields(structInfo []fieldInfo, headers []string) []string {
 	}
 
 	for _, header := range headers {
-		if _, ok := keyMap[header]; !ok {
+		if _, ok := keyMap[header]; !ok && !isIgnoredColumn(header) {
 			missing = append(missing, header)
 		}
 	}
@@ -108,6 +446,65 @@ func maybeMissingStructFields(structInfo []fieldInfo, headers []string) error {
 	return nil
 }
 
+// maybeUnmatchedColumns passes the headers of the columns matching no field to the
+// handler set with SetUnmatchedColumnHandler, and returns an error naming them when
+// FailIfUnmatchedColumns is set.
+func maybeUnmatchedColumns(headers []string, csvHeadersLabels map[int]*fieldInfo) error {
+	var unmatched []string
+	for i, header := range headers {
+		if _, ok := csvHeadersLabels[i]; !ok && !isIgnoredColumn(header) {
+			unmatched = append(unmatched, header)
+			if unmatchedColumnHandler != nil {
+				unmatchedColumnHandler(header)
+			}
+		}
+	}
+	if len(unmatched) != 0 && FailIfUnmatchedColumns {
+		return fmt.Errorf("found unmatched csv columns %v", unmatched)
+	}
+	return nil
+}
+
+// invalidUTF8Cell returns the index of the first cell of row that is not valid UTF-8,
+// or -1 when every cell is valid or SetRejectInvalidUTF8 is off.
+func invalidUTF8Cell(row []string) int {
+	if !rejectInvalidUTF8 {
+		return -1
+	}
+	for j, cell := range row {
+		if !utf8.ValidString(cell) {
+			return j
+		}
+	}
+	return -1
+}
+
+// headerAt returns the header of column j, or its position when the row is longer than the header
+func headerAt(headers []string, j int) string {
+	if j < len(headers) {
+		return headers[j]
+	}
+	return strconv.Itoa(j + 1)
+}
+
+func invalidUTF8Error(line, j int, column, value string) *csv.ParseError {
+	return &csv.ParseError{
+		Line:   line,
+		Column: j + 1,
+		Err:    &ConversionError{Line: line, Column: column, Value: value, Err: ErrInvalidUTF8},
+	}
+}
+
+// isIgnoredColumn reports whether the normalized header was set with SetIgnoredColumns.
+func isIgnoredColumn(header string) bool {
+	for _, ignored := range ignoredColumns {
+		if normalizeName(ignored) == header {
+			return true
+		}
+	}
+	return false
+}
+
 // Check that no header name is repeated twice
 func maybeDoubleHeaderNames(headers []string) error {
 	headerMap := make(map[string]bool, len(headers))
@@ -120,20 +517,205 @@ func maybeDoubleHeaderNames(headers []string) error {
 	return nil
 }
 
-// apply normalizer func to headers
+// trim and strip the prefix (when set), apply header aliases then normalizer func to headers,
+// and suffix the repeated ones with DuplicateHeaderSuffix
 func normalizeHeaders(headers []string) []string {
 	out := make([]string, len(headers))
 	for i, h := range headers {
+		if trimHeaders {
+			h = strings.TrimSpace(h)
+		}
+		h = strings.TrimPrefix(h, headerPrefixStrip)
+		if alias, ok := headerAliases[h]; ok {
+			h = alias
+		}
 		out[i] = normalizeName(h)
 	}
+	if duplicateHeaderStrategy == DuplicateHeaderSuffix {
+		suffixDuplicateHeaders(out)
+	}
 	return out
 }
 
+// suffixDuplicateHeaders renames the second occurrence of a header to header_2, the
+// third to header_3 and so on, skipping the names that are already headers.
+func suffixDuplicateHeaders(headers []string) {
+	taken := make(map[string]bool, len(headers))
+	for _, h := range headers {
+		taken[h] = true
+	}
+	seen := make(map[string]int, len(headers))
+	for i, h := range headers {
+		seen[h]++
+		if seen[h] == 1 {
+			continue
+		}
+		n := seen[h]
+		name := fmt.Sprintf("%s_%d", h, n)
+		for taken[name] {
+			n++
+			name = fmt.Sprintf("%s_%d", h, n)
+		}
+		seen[h] = n
+		taken[name] = true
+		headers[i] = name
+	}
+}
+
 func readTo(decoder Decoder, out interface{}) error {
 	return readToWithErrorHandler(decoder, nil, out)
 }
 
+func readToAppend(decoder Decoder, out interface{}) error {
+	outValue := reflect.ValueOf(out)
+	if outValue.Kind() != reflect.Ptr || outValue.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("cannot use %T, only pointer to slice supported", out)
+	}
+	decoded := reflect.New(outValue.Elem().Type())
+	if err := readTo(decoder, decoded.Interface()); err != nil {
+		return err
+	}
+	outValue.Elem().Set(reflect.AppendSlice(outValue.Elem(), decoded.Elem()))
+	return nil
+}
+
+func readToGrouped(decoder Decoder, out interface{}, keyColumn string) error {
+	outValue := reflect.ValueOf(out)
+	if outValue.Kind() != reflect.Ptr || outValue.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("cannot use %T, only pointer to slice supported", out)
+	}
+	outType := outValue.Elem().Type()
+	outInnerWasPointer, outInnerType := getConcreteContainerInnerType(outType)
+	if err := ensureOutInnerType(outInnerType); err != nil {
+		return err
+	}
+	childFields := groupedChildFields(outInnerType)
+	if len(childFields) == 0 {
+		return fmt.Errorf("cannot group rows into %s, it has no slice of struct field", outInnerType)
+	}
+	csvRows, err := decoder.GetCSVRows()
+	if err != nil {
+		return err
+	}
+	if len(csvRows) == 0 {
+		return ErrEmptyCSVFile
+	}
+	keyIndex := -1
+	for i, header := range normalizeHeaders(csvRows[0]) {
+		if header == normalizeName(keyColumn) {
+			keyIndex = i
+			break
+		}
+	}
+	if keyIndex < 0 {
+		return fmt.Errorf("key column %s not found in csv header", keyColumn)
+	}
+
+	// Every row is decoded both as a parent and as a child, so that errors keep the
+	// line of the CSV, then the parent of the first row of a group gets its children.
+	parents := reflect.New(outType)
+	if err := readTo(rowsDecoder(csvRows), parents.Interface()); err != nil {
+		return err
+	}
+	children := make([]reflect.Value, len(childFields))
+	for i, field := range childFields {
+		children[i] = reflect.New(outInnerType.Field(field).Type)
+		if err := readTo(rowsDecoder(csvRows), children[i].Interface()); err != nil {
+			return err
+		}
+	}
+	body := csvRows[1:]
+	grouped := reflect.MakeSlice(outType, 0, 0)
+	for start := 0; start < len(body); {
+		end := start + 1
+		for end < len(body) && body[end][keyIndex] == body[start][keyIndex] {
+			end++
+		}
+		parent := parents.Elem().Index(start)
+		if !outInnerWasPointer || !parent.IsNil() { // nil with SetNilForEmptyRows
+			parentStruct := reflect.Indirect(parent)
+			for i, field := range childFields {
+				parentStruct.Field(field).Set(children[i].Elem().Slice(start, end))
+			}
+		}
+		grouped = reflect.Append(grouped, parent)
+		start = end
+	}
+	outValue.Elem().Set(grouped)
+	return nil
+}
+
+// groupedChildFields returns the indexes of the exported slice of struct fields of t
+// that have no csv[] length, the fields children are decoded into by UnmarshalGrouped.
+func groupedChildFields(t reflect.Type) []int {
+	var fields []int
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || field.Type.Kind() != reflect.Slice || field.Tag.Get(TagName) == "-" {
+			continue
+		}
+		if _, ok := field.Tag.Lookup(TagName + "[]"); ok {
+			continue
+		}
+		if _, elemType := getConcreteContainerInnerType(field.Type); elemType.Kind() == reflect.Struct {
+			fields = append(fields, i)
+		}
+	}
+	return fields
+}
+
+// CoercionReport counts, per column header, how the cells of a decoded CSV were
+// converted to their fields.
+type CoercionReport struct {
+	Columns map[string]*ColumnCoercion
+
+	mutex sync.Mutex // rows are counted from several goroutines when decoding in parallel
+}
+
+// ColumnCoercion counts the cells of a column by how they were converted.
+type ColumnCoercion struct {
+	Parsed    int // cells converted as written
+	Empty     int // empty cells, leaving the zero value
+	Defaulted int // empty cells replaced by the default tag option
+	Fallback  int // cells that could not be converted, passed over by the error handler or clamped
+}
+
+func newCoercionReport() *CoercionReport {
+	return &CoercionReport{Columns: make(map[string]*ColumnCoercion)}
+}
+
+// count adds a cell of column, converted from value, to the report, which may be nil.
+func (r *CoercionReport) count(column, value string, fieldInfo *fieldInfo, fallback bool) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	counts, ok := r.Columns[column]
+	if !ok {
+		counts = &ColumnCoercion{}
+		r.Columns[column] = counts
+	}
+	switch {
+	case fallback:
+		counts.Fallback++
+	case value != "":
+		counts.Parsed++
+	case fieldInfo.defaultValue != "":
+		counts.Defaulted++
+	default:
+		counts.Empty++
+	}
+}
+
 func readToWithErrorHandler(decoder Decoder, errHandler ErrorHandler, out interface{}) error {
+	return readToWithCoercionReport(decoder, errHandler, out, nil, false)
+}
+
+// readToWithCoercionReport decodes the rows in out, counting the conversions in report
+// unless it is nil. With inPlace, each row is decoded in the element of out at its
+// index, which must have as many elements as rows, only setting the mapped fields.
+func readToWithCoercionReport(decoder Decoder, errHandler ErrorHandler, out interface{}, report *CoercionReport, inPlace bool) error {
 	outValue, outType := getConcreteReflectValueAndType(out) // Get the concrete type (not pointer) (Slice<?> or Array<?>)
 	if err := ensureOutType(outType); err != nil {
 		return err
@@ -149,13 +731,26 @@ func readToWithErrorHandler(decoder Decoder, errHandler ErrorHandler, out interf
 	if len(csvRows) == 0 {
 		return ErrEmptyCSVFile
 	}
-	if err := ensureOutCapacity(&outValue, len(csvRows)); err != nil { // Ensure the container is big enough to hold the CSV content
-		return err
-	}
 	outInnerStructInfo := getStructInfo(outInnerType) // Get the inner struct info to get CSV annotations
+	if outInnerStructInfo.err != nil {
+		return outInnerStructInfo.err
+	}
 	if len(outInnerStructInfo.Fields) == 0 {
 		return ErrNoStructTags
 	}
+	headerIndex, err := findHeaderRow(csvRows, outInnerStructInfo)
+	if err != nil {
+		return err
+	}
+	csvRows = csvRows[headerIndex:]
+	firstLine := headerIndex + 2 // the line of the first row, after the header
+
+	if inPlace && outValue.Len() != len(csvRows)-1 {
+		return fmt.Errorf("cannot decode %d rows in place into %d elements", len(csvRows)-1, outValue.Len())
+	}
+	if err := ensureOutCapacity(&outValue, len(csvRows)); err != nil { // Ensure the container is big enough to hold the CSV content
+		return err
+	}
 
 	headers := normalizeHeaders(csvRows[0])
 	body := csvRows[1:]
@@ -164,6 +759,13 @@ func readToWithErrorHandler(decoder Decoder, errHandler ErrorHandler, out interf
 
 	headerCount := map[string]int{}
 	for i, csvColumnHeader := range headers {
+		if fieldInfo := getCSVIndexedField(i, outInnerStructInfo); fieldInfo != nil {
+			csvHeadersLabels[i] = fieldInfo
+			continue
+		}
+		if isIgnoredColumn(csvColumnHeader) {
+			continue
+		}
 		curHeaderCount := headerCount[csvColumnHeader]
 		if fieldInfo := getCSVFieldPosition(csvColumnHeader, outInnerStructInfo, curHeaderCount); fieldInfo != nil {
 			csvHeadersLabels[i] = fieldInfo
@@ -174,34 +776,68 @@ func readToWithErrorHandler(decoder Decoder, errHandler ErrorHandler, out interf
 		}
 	}
 
+	if FailIfUnmatchedColumns || unmatchedColumnHandler != nil {
+		if err := maybeUnmatchedColumns(headers, csvHeadersLabels); err != nil {
+			keepPartialResults(outValue, 0, inPlace)
+			return err
+		}
+	}
+	computed := getComputedColumns(outInnerStructInfo, headers, csvHeadersLabels)
+
 	if FailIfUnmatchedStructTags {
-		if err := maybeMissingStructFields(outInnerStructInfo.Fields, headers); err != nil {
+		if err := maybeMissingStructFields(outInnerStructInfo.Fields, computed.withKeys(headers)); err != nil {
+			keepPartialResults(outValue, 0, inPlace)
 			return err
 		}
 	}
 	if FailIfDoubleHeaderNames {
 		if err := maybeDoubleHeaderNames(headers); err != nil {
+			keepPartialResults(outValue, 0, inPlace)
 			return err
 		}
 	}
 
-	var withFieldsOK bool
-	var fieldTypeUnmarshallerWithKeys TypeUnmarshalCSVWithFields
+	var errHandlerMutex sync.Mutex // errHandler is called from several goroutines when decoding in parallel
+
+	decodeRow := func(i int) error {
+		csvRow := body[i]
+		if nilForEmptyRows && outInnerWasPointer && isEmptyRow(csvRow) {
+			outValue.Index(i).Set(reflect.Zero(outValue.Type().Elem()))
+			return nil
+		}
+		if j := invalidUTF8Cell(csvRow); j >= 0 {
+			return invalidUTF8Error(i+firstLine, j, headerAt(headers, j), csvRow[j])
+		}
+		var withFieldsOK bool
+		var fieldTypeUnmarshallerWithKeys TypeUnmarshalCSVWithFields
 
-	for i, csvRow := range body {
 		objectIface := reflect.New(outValue.Index(i).Type()).Interface()
 		outInner := createNewOutInner(outInnerWasPointer, outInnerType)
+		if inPlace {
+			outInner = outValue.Index(i)
+			objectIface = outInner.Addr().Interface()
+		}
+		if err := computed.set(&outInner, outInnerWasPointer, csvRow, i+firstLine); err != nil {
+			return err
+		}
 		for j, csvColumnContent := range csvRow {
 			if fieldInfo, ok := csvHeadersLabels[j]; ok { // Position found accordingly to header name
 
 				if outInner.CanInterface() {
 					fieldTypeUnmarshallerWithKeys, withFieldsOK = objectIface.(TypeUnmarshalCSVWithFields)
 					if withFieldsOK {
-						if err := fieldTypeUnmarshallerWithKeys.UnmarshalCSVWithFields(fieldInfo.getFirstKey(), csvColumnContent); err != nil {
+						if err := checkFieldLength(csvColumnContent); err != nil {
+							return &csv.ParseError{
+								Line:   i + firstLine,
+								Column: j + 1,
+								Err:    &ConversionError{Line: i + firstLine, Column: headers[j], Value: csvColumnContent, Err: err},
+							}
+						}
+						if err := unmarshalCSVWithFields(fieldTypeUnmarshallerWithKeys, fieldInfo.getFirstKey(), csvColumnContent); err != nil {
 							parseError := csv.ParseError{
-								Line:   i + 2, //add 2 to account for the header & 0-indexing of arrays
+								Line:   i + firstLine,
 								Column: j + 1,
-								Err:    err,
+								Err:    &ConversionError{Line: i + firstLine, Column: headers[j], Value: csvColumnContent, Err: err},
 							}
 							return &parseError
 						}
@@ -209,19 +845,31 @@ func readToWithErrorHandler(decoder Decoder, errHandler ErrorHandler, out interf
 					}
 				}
 				value := csvColumnContent
-				if value == "" {
+				if isEmptyCell(value) {
 					value = fieldInfo.defaultValue
 				}
-				if err := setInnerField(&outInner, outInnerWasPointer, fieldInfo.IndexChain, value, fieldInfo.omitEmpty); err != nil { // Set field of struct
-					parseError := csv.ParseError{
-						Line:   i + 2, //add 2 to account for the header & 0-indexing of arrays
-						Column: j + 1,
-						Err:    err,
-					}
-					if errHandler == nil || !errHandler(&parseError) {
-						return &parseError
+				if err := setInnerField(&outInner, outInnerWasPointer, fieldInfo.IndexChain, value, fieldInfo); err != nil { // Set field of struct
+					convErr := &ConversionError{Line: i + firstLine, Column: headers[j], Value: value, Err: err}
+					if !isClampWarning(convErr) {
+						parseError := csv.ParseError{
+							Line:   i + firstLine,
+							Column: j + 1,
+							Err:    parseErrorCause(convErr),
+						}
+						if errHandler == nil {
+							return &parseError
+						}
+						errHandlerMutex.Lock()
+						handled := errHandler(&parseError)
+						errHandlerMutex.Unlock()
+						if !handled {
+							return &parseError
+						}
 					}
+					report.count(headers[j], csvColumnContent, fieldInfo, true)
+					continue
 				}
+				report.count(headers[j], csvColumnContent, fieldInfo, false)
 			}
 		}
 
@@ -231,8 +879,86 @@ func readToWithErrorHandler(decoder Decoder, errHandler ErrorHandler, out interf
 		}
 
 		outValue.Index(i).Set(outInner)
+		return nil
+	}
+	failed, err := decodeRows(len(body), decodeRow)
+	if err != nil {
+		keepPartialResults(outValue, failed, inPlace)
+	}
+	return err
+}
+
+// parseErrorCause returns the Err of the *csv.ParseError reporting convErr: the
+// error of a TypeUnmarshaller or encoding.TextUnmarshaler as it is, or else convErr.
+func parseErrorCause(convErr *ConversionError) error {
+	var custom unmarshallerError
+	if errors.As(convErr.Err, &custom) {
+		return custom.err
+	}
+	return convErr
+}
+
+// keepPartialResults shortens the decoded slice to the n rows decoded before an
+// error, when SetPartialResults is enabled.
+func keepPartialResults(outValue reflect.Value, n int, inPlace bool) {
+	if partialResults && !inPlace && outValue.Kind() == reflect.Slice && outValue.CanSet() {
+		outValue.Set(outValue.Slice(0, n))
 	}
-	return nil
+}
+
+// decodeRows calls decodeRow for each row index in [0, n). The rows are spread
+// over the goroutines set by SetParallelism, but the returned error is always the
+// one of the first failing row, as if the rows had been decoded sequentially, along
+// with its index, n when no row failed.
+func decodeRows(n int, decodeRow func(i int) error) (int, error) {
+	workers := parallelism
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			if err := decodeRow(i); err != nil {
+				return i, err
+			}
+		}
+		return n, nil
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		next     int
+		errIndex = n
+		firstErr error
+	)
+	// Rows are handed out in order, so once a row failed every row before it
+	// has already been picked up and only later rows can be skipped.
+	nextRow := func() (int, bool) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if next >= n || firstErr != nil {
+			return 0, false
+		}
+		next++
+		return next - 1, true
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i, ok := nextRow(); ok; i, ok = nextRow() {
+				if err := decodeRow(i); err != nil {
+					mutex.Lock()
+					if i < errIndex {
+						errIndex, firstErr = i, err
+					}
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return errIndex, firstErr
 }
 
 func readEach(decoder SimpleDecoder, c interface{}) error {
@@ -242,23 +968,65 @@ func readEach(decoder SimpleDecoder, c interface{}) error {
 	}
 	defer outValue.Close()
 
-	headers, err := decoder.GetCSVRow()
-	if err != nil {
-		return err
-	}
-	headers = normalizeHeaders(headers)
+	return readEachFunc(decoder, outType.Elem(), false, func(outInner reflect.Value, _ []bool) error {
+		outValue.Send(outInner)
+		return nil
+	})
+}
 
-	outInnerWasPointer, outInnerType := getConcreteContainerInnerType(outType) // Get the concrete inner type (not pointer) (Container<"?">)
+// readEachFunc decodes each row to a value of elemType, a struct or a pointer to one,
+// passed to send. With withMask, send is also passed whether each field of the struct
+// info is set from a non-empty cell, else nil. Reading stops at the first error of send.
+func readEachFunc(decoder SimpleDecoder, elemType reflect.Type, withMask bool, send func(reflect.Value, []bool) error) error {
+	outInnerWasPointer, outInnerType := elemType.Kind() == reflect.Ptr, elemType
+	if outInnerWasPointer {
+		outInnerType = elemType.Elem()
+	}
 	if err := ensureOutInnerType(outInnerType); err != nil {
 		return err
 	}
 	outInnerStructInfo := getStructInfo(outInnerType) // Get the inner struct info to get CSV annotations
+	if outInnerStructInfo.err != nil {
+		return outInnerStructInfo.err
+	}
 	if len(outInnerStructInfo.Fields) == 0 {
 		return ErrNoStructTags
 	}
+
+	firstLine := 2 // the line of the first row, after the header
+	if headerSearchDepth > 1 {
+		var candidates [][]string
+		for len(candidates) < headerSearchDepth {
+			row, err := decoder.GetCSVRow()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+			candidates = append(candidates, row)
+		}
+		headerIndex, err := findHeaderRow(candidates, outInnerStructInfo)
+		if err != nil {
+			return err
+		}
+		firstLine += headerIndex
+		decoder = &prependRowsDecoder{SimpleDecoder: decoder, rows: candidates[headerIndex:]}
+	}
+	headers, err := decoder.GetCSVRow()
+	if err != nil {
+		return err
+	}
+	headers = normalizeHeaders(headers)
 	csvHeadersLabels := make(map[int]*fieldInfo, len(outInnerStructInfo.Fields)) // Used to store the correspondance header <-> position in CSV
 	headerCount := map[string]int{}
 	for i, csvColumnHeader := range headers {
+		if fieldInfo := getCSVIndexedField(i, outInnerStructInfo); fieldInfo != nil {
+			csvHeadersLabels[i] = fieldInfo
+			continue
+		}
+		if isIgnoredColumn(csvColumnHeader) {
+			continue
+		}
 		curHeaderCount := headerCount[csvColumnHeader]
 		if fieldInfo := getCSVFieldPosition(csvColumnHeader, outInnerStructInfo, curHeaderCount); fieldInfo != nil {
 			csvHeadersLabels[i] = fieldInfo
@@ -268,7 +1036,13 @@ func readEach(decoder SimpleDecoder, c interface{}) error {
 			}
 		}
 	}
-	if err := maybeMissingStructFields(outInnerStructInfo.Fields, headers); err != nil {
+	if FailIfUnmatchedColumns || unmatchedColumnHandler != nil {
+		if err := maybeUnmatchedColumns(headers, csvHeadersLabels); err != nil {
+			return err
+		}
+	}
+	computed := getComputedColumns(outInnerStructInfo, headers, csvHeadersLabels)
+	if err := maybeMissingStructFields(outInnerStructInfo.Fields, computed.withKeys(headers)); err != nil {
 		if FailIfUnmatchedStructTags {
 			return err
 		}
@@ -278,6 +1052,13 @@ func readEach(decoder SimpleDecoder, c interface{}) error {
 			return err
 		}
 	}
+	var fieldIndexes map[int]int // the index in the struct info of the field of each column
+	if withMask {
+		fieldIndexes = make(map[int]int, len(csvHeadersLabels))
+		for j, fieldInfo := range csvHeadersLabels {
+			fieldIndexes[j] = structFieldIndex(outInnerStructInfo, fieldInfo)
+		}
+	}
 	i := 0
 	for {
 		line, err := decoder.GetCSVRow()
@@ -286,19 +1067,45 @@ func readEach(decoder SimpleDecoder, c interface{}) error {
 		} else if err != nil {
 			return err
 		}
+		var populated []bool
+		if withMask {
+			populated = make([]bool, len(outInnerStructInfo.Fields))
+		}
+		if nilForEmptyRows && outInnerWasPointer && isEmptyRow(line) {
+			if err := send(reflect.Zero(elemType), populated); err != nil {
+				return err
+			}
+			i++
+			continue
+		}
+		if j := invalidUTF8Cell(line); j >= 0 {
+			return invalidUTF8Error(i+firstLine, j, headerAt(headers, j), line[j])
+		}
 		outInner := createNewOutInner(outInnerWasPointer, outInnerType)
+		if err := computed.set(&outInner, outInnerWasPointer, line, i+firstLine); err != nil {
+			return err
+		}
 		for j, csvColumnContent := range line {
 			if fieldInfo, ok := csvHeadersLabels[j]; ok { // Position found accordingly to header name
-				if err := setInnerField(&outInner, outInnerWasPointer, fieldInfo.IndexChain, csvColumnContent, fieldInfo.omitEmpty); err != nil { // Set field of struct
+				if withMask && !isEmptyCell(csvColumnContent) {
+					populated[fieldIndexes[j]] = true
+				}
+				if err := setInnerField(&outInner, outInnerWasPointer, fieldInfo.IndexChain, csvColumnContent, fieldInfo); err != nil { // Set field of struct
+					convErr := &ConversionError{Line: i + firstLine, Column: headers[j], Value: csvColumnContent, Err: err}
+					if isClampWarning(convErr) {
+						continue
+					}
 					return &csv.ParseError{
-						Line:   i + 2, //add 2 to account for the header & 0-indexing of arrays
+						Line:   i + firstLine,
 						Column: j + 1,
-						Err:    err,
+						Err:    parseErrorCause(convErr),
 					}
 				}
 			}
 		}
-		outValue.Send(outInner)
+		if err := send(outInner, populated); err != nil {
+			return err
+		}
 		i++
 	}
 	return nil
@@ -316,6 +1123,9 @@ func readEachWithoutHeaders(decoder SimpleDecoder, c interface{}) error {
 		return err
 	}
 	outInnerStructInfo := getStructInfo(outInnerType) // Get the inner struct info to get CSV annotations
+	if outInnerStructInfo.err != nil {
+		return outInnerStructInfo.err
+	}
 	if len(outInnerStructInfo.Fields) == 0 {
 		return ErrNoStructTags
 	}
@@ -328,14 +1138,29 @@ func readEachWithoutHeaders(decoder SimpleDecoder, c interface{}) error {
 		} else if err != nil {
 			return err
 		}
+		if nilForEmptyRows && outInnerWasPointer && isEmptyRow(line) {
+			outValue.Send(reflect.Zero(outValue.Type().Elem()))
+			i++
+			continue
+		}
+		if j := invalidUTF8Cell(line); j >= 0 {
+			return invalidUTF8Error(i+2, j, outInnerStructInfo.Fields[j].getFirstKey(), line[j])
+		}
 		outInner := createNewOutInner(outInnerWasPointer, outInnerType)
 		for j, csvColumnContent := range line {
-			fieldInfo := outInnerStructInfo.Fields[j]
-			if err := setInnerField(&outInner, outInnerWasPointer, fieldInfo.IndexChain, csvColumnContent, fieldInfo.omitEmpty); err != nil { // Set field of struct
+			fieldInfo := &outInnerStructInfo.Fields[j]
+			if fieldInfo.multiColumn {
+				continue
+			}
+			if err := setInnerField(&outInner, outInnerWasPointer, fieldInfo.IndexChain, csvColumnContent, fieldInfo); err != nil { // Set field of struct
+				convErr := &ConversionError{Line: i + 2, Column: fieldInfo.getFirstKey(), Value: csvColumnContent, Err: err}
+				if isClampWarning(convErr) {
+					continue
+				}
 				return &csv.ParseError{
 					Line:   i + 2, //add 2 to account for the header & 0-indexing of arrays
 					Column: j + 1,
-					Err:    err,
+					Err:    parseErrorCause(convErr),
 				}
 			}
 		}
@@ -365,19 +1190,36 @@ func readToWithoutHeaders(decoder Decoder, out interface{}) error {
 		return err
 	}
 	outInnerStructInfo := getStructInfo(outInnerType) // Get the inner struct info to get CSV annotations
+	if outInnerStructInfo.err != nil {
+		return outInnerStructInfo.err
+	}
 	if len(outInnerStructInfo.Fields) == 0 {
 		return ErrNoStructTags
 	}
 
 	for i, csvRow := range csvRows {
+		if nilForEmptyRows && outInnerWasPointer && isEmptyRow(csvRow) {
+			outValue.Index(i).Set(reflect.Zero(outValue.Type().Elem()))
+			continue
+		}
+		if j := invalidUTF8Cell(csvRow); j >= 0 {
+			return invalidUTF8Error(i+1, j, outInnerStructInfo.Fields[j].getFirstKey(), csvRow[j])
+		}
 		outInner := createNewOutInner(outInnerWasPointer, outInnerType)
 		for j, csvColumnContent := range csvRow {
-			fieldInfo := outInnerStructInfo.Fields[j]
-			if err := setInnerField(&outInner, outInnerWasPointer, fieldInfo.IndexChain, csvColumnContent, fieldInfo.omitEmpty); err != nil { // Set field of struct
+			fieldInfo := &outInnerStructInfo.Fields[j]
+			if fieldInfo.multiColumn {
+				continue
+			}
+			if err := setInnerField(&outInner, outInnerWasPointer, fieldInfo.IndexChain, csvColumnContent, fieldInfo); err != nil { // Set field of struct
+				convErr := &ConversionError{Line: i + 1, Column: fieldInfo.getFirstKey(), Value: csvColumnContent, Err: err}
+				if isClampWarning(convErr) {
+					continue
+				}
 				return &csv.ParseError{
 					Line:   i + 1,
 					Column: j + 1,
-					Err:    err,
+					Err:    parseErrorCause(convErr),
 				}
 			}
 		}
@@ -425,10 +1267,21 @@ func ensureOutCapacity(out *reflect.Value, csvLen int) error {
 	return nil
 }
 
+// getCSVIndexedField returns the field mapped to the column i with the index:n tag
+// option, which wins over the fields matching the header of the column, or nil.
+func getCSVIndexedField(i int, structInfo *structInfo) *fieldInfo {
+	for _, field := range structInfo.Fields {
+		if field.indexed && field.columnIndex == i {
+			return &field
+		}
+	}
+	return nil
+}
+
 func getCSVFieldPosition(key string, structInfo *structInfo, curHeaderCount int) *fieldInfo {
 	matchedFieldCount := 0
 	for _, field := range structInfo.Fields {
-		if field.matchesKey(key) {
+		if !field.multiColumn && !field.indexed && field.matchesKey(key) {
 			if matchedFieldCount >= curHeaderCount {
 				return &field
 			}
@@ -438,6 +1291,140 @@ func getCSVFieldPosition(key string, structInfo *structInfo, curHeaderCount int)
 	return nil
 }
 
+// structFieldIndex returns the index in structInfo of the field described by fieldInfo,
+// a copy of it as returned by getCSVFieldPosition.
+func structFieldIndex(structInfo *structInfo, fieldInfo *fieldInfo) int {
+	for i, field := range structInfo.Fields {
+		if !field.multiColumn && reflect.DeepEqual(field.IndexChain, fieldInfo.IndexChain) {
+			return i
+		}
+	}
+	return -1
+}
+
+// computedColumn is a registered computed field resolved against a struct
+type computedColumn struct {
+	fieldInfo *fieldInfo
+	compute   ComputedFieldFunc
+}
+
+// call calls compute, recovering from its panics when set with SetRecoverFromPanics.
+func (c computedColumn) call(record []string, headerIndex map[string]int) (value string, err error) {
+	if recoverFromPanics {
+		defer recoverCallbackPanic("RegisterComputedField", &err)
+	}
+	return c.compute(record, headerIndex)
+}
+
+type computedColumns struct {
+	columns     []computedColumn
+	headerIndex map[string]int
+}
+
+// getComputedColumns resolves the registered computed fields of structInfo. The CSV
+// columns mapped to a computed field are removed from csvHeadersLabels, the computed
+// value taking precedence.
+func getComputedColumns(structInfo *structInfo, headers []string, csvHeadersLabels map[int]*fieldInfo) computedColumns {
+	var computed computedColumns
+	if len(computedFields) == 0 {
+		return computed
+	}
+	for i := range structInfo.Fields {
+		field := &structInfo.Fields[i]
+		if field.multiColumn {
+			continue
+		}
+		for _, key := range computedFieldKeys {
+			if field.matchesKey(normalizeName(key)) {
+				computed.columns = append(computed.columns, computedColumn{field, computedFields[key]})
+				break
+			}
+		}
+	}
+	if len(computed.columns) == 0 {
+		return computed
+	}
+	for j, fieldInfo := range csvHeadersLabels {
+		for _, column := range computed.columns {
+			if reflect.DeepEqual(column.fieldInfo.IndexChain, fieldInfo.IndexChain) {
+				delete(csvHeadersLabels, j)
+			}
+		}
+	}
+	computed.headerIndex = make(map[string]int, len(headers))
+	for j, header := range headers {
+		if _, ok := computed.headerIndex[header]; !ok {
+			computed.headerIndex[header] = j
+		}
+	}
+	return computed
+}
+
+// withKeys returns the headers extended with the keys of the computed fields,
+// which are not missing even if no CSV column provides them.
+func (c computedColumns) withKeys(headers []string) []string {
+	if len(c.columns) == 0 {
+		return headers
+	}
+	keys := append([]string(nil), headers...)
+	for _, column := range c.columns {
+		keys = append(keys, column.fieldInfo.keys...)
+	}
+	return keys
+}
+
+// set sets the computed fields of outInner from record, the CSV line line. Its
+// errors are csv.ParseErrors wrapping a ConversionError.
+func (c computedColumns) set(outInner *reflect.Value, outInnerWasPointer bool, record []string, line int) error {
+	for k, column := range c.columns {
+		value, err := column.call(record, c.headerIndex)
+		if err != nil {
+			return &csv.ParseError{
+				Line:   line,
+				Column: c.position(k, record),
+				Err:    &ConversionError{Line: line, Column: column.fieldInfo.getFirstKey(), Err: err},
+			}
+		}
+		if isEmptyCell(value) {
+			value = column.fieldInfo.defaultValue
+		}
+		if err := setInnerField(outInner, outInnerWasPointer, column.fieldInfo.IndexChain, value, column.fieldInfo); err != nil {
+			convErr := &ConversionError{Line: line, Column: column.fieldInfo.getFirstKey(), Value: value, Err: err}
+			if isClampWarning(convErr) {
+				continue
+			}
+			return &csv.ParseError{
+				Line:   line,
+				Column: c.position(k, record),
+				Err:    parseErrorCause(convErr),
+			}
+		}
+	}
+	return nil
+}
+
+// position returns the 1-based column of the k-th computed field: the one of the
+// CSV column matching the field, or else one after the columns of record, as if
+// the computed fields were appended to it.
+func (c computedColumns) position(k int, record []string) int {
+	for _, key := range c.columns[k].fieldInfo.keys {
+		if j, ok := c.headerIndex[key]; ok {
+			return j + 1
+		}
+	}
+	return len(record) + k + 1
+}
+
+// isEmptyRow reports whether every cell of row is empty.
+func isEmptyRow(row []string) bool {
+	for _, cell := range row {
+		if cell != "" {
+			return false
+		}
+	}
+	return true
+}
+
 func createNewOutInner(outInnerWasPointer bool, outInnerType reflect.Type) reflect.Value {
 	if outInnerWasPointer {
 		return reflect.New(outInnerType)
@@ -445,12 +1432,12 @@ func createNewOutInner(outInnerWasPointer bool, outInnerType reflect.Type) refle
 	return reflect.New(outInnerType).Elem()
 }
 
-func setInnerField(outInner *reflect.Value, outInnerWasPointer bool, index []int, value string, omitEmpty bool) error {
+func setInnerField(outInner *reflect.Value, outInnerWasPointer bool, index []int, value string, fieldInfo *fieldInfo) error {
 	oi := *outInner
 	if outInnerWasPointer {
 		// initialize nil pointer
 		if oi.IsNil() {
-			setField(oi, "", omitEmpty)
+			oi.Set(reflect.New(oi.Type().Elem()))
 		}
 		oi = outInner.Elem()
 	}
@@ -474,15 +1461,15 @@ func setInnerField(outInner *reflect.Value, outInnerWasPointer bool, index []int
 
 		item := oi.Index(i)
 		if len(index) > 1 {
-			return setInnerField(&item, false, index[1:], value, omitEmpty)
+			return setInnerField(&item, false, index[1:], value, fieldInfo)
 		}
-		return setField(item, value, omitEmpty)
+		return setFieldInfo(item, value, fieldInfo)
 	}
 
 	// because pointers can be nil need to recurse one index at a time and perform nil check
 	if len(index) > 1 {
 		nextField := oi.Field(index[0])
-		return setInnerField(&nextField, nextField.Kind() == reflect.Ptr, index[1:], value, omitEmpty)
+		return setInnerField(&nextField, nextField.Kind() == reflect.Ptr, index[1:], value, fieldInfo)
 	}
-	return setField(oi.FieldByIndex(index), value, omitEmpty)
+	return setFieldInfo(oi.FieldByIndex(index), value, fieldInfo)
 }
@@ -3,7 +3,9 @@ package gocsv
 import (
 	"bytes"
 	"encoding/csv"
+	"errors"
 	"io"
+	"io/ioutil"
 	"reflect"
 	"strconv"
 	"strings"
@@ -549,6 +551,8 @@ func TestRenamedTypesUnmarshal(t *testing.T) {
 		t.Fatalf("Expected ParseError, got nil.")
 	} else if _, ok := perr.Err.(UnmarshalError); !ok {
 		t.Fatalf("Expected UnmarshalError, got %v", perr.Err)
+	} else if !errors.As(perr.Err, &UnmarshalError{}) {
+		t.Fatalf("Expected errors.As to find the UnmarshalError, got %v", perr.Err)
 	}
 }
 
@@ -624,6 +628,12 @@ baz,2,zap,4.00`)
 
 		})
 	}
+
+	err = UnmarshalBytes([]byte("foo,bar,baz,frop\nbar,x,zip,3.14"), &samples)
+	var convErr *ConversionError
+	if !errors.As(err, &convErr) || convErr.Line != 2 || convErr.Column != "bar" || convErr.Value != "x" {
+		t.Errorf("expected a ConversionError from UnmarshalCSVWithFields, got %v", err)
+	}
 }
 
 func (u *UnmarshalCSVWithFieldsSample) UnmarshalCSVWithFields(key, value string) error {
@@ -1036,3 +1046,1366 @@ false,email_one,true,email_two,false,email_three`)
 		t.Fatalf("expected \n  sample: %v\n     got: %v", expected, samples[0])
 	}
 }
+
+// endlessCSV is an io.Reader producing a header followed by an infinite number of rows.
+type endlessCSV struct {
+	pending []byte
+	read    int64
+}
+
+func (e *endlessCSV) Read(p []byte) (int, error) {
+	if e.pending == nil {
+		e.pending = []byte("foo,BAR\n")
+	}
+	n := 0
+	for n < len(p) {
+		if len(e.pending) == 0 {
+			e.pending = []byte("a,1\n")
+		}
+		c := copy(p[n:], e.pending)
+		e.pending = e.pending[c:]
+		n += c
+	}
+	e.read += int64(n)
+	return n, nil
+}
+
+func TestMaxRows(t *testing.T) {
+	SetMaxRows(3)
+	defer SetMaxRows(0)
+
+	var samples []Sample
+	if err := UnmarshalString("foo,BAR\na,1\nb,2", &samples); err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 samples, got %d", len(samples))
+	}
+
+	if err := Unmarshal(&endlessCSV{}, &samples); err != ErrMaxRowsExceeded {
+		t.Fatalf("expected ErrMaxRowsExceeded, got %v", err)
+	}
+
+	c := make(chan Sample)
+	go func() {
+		for range c {
+		}
+	}()
+	if err := UnmarshalToChan(&endlessCSV{}, c); err != ErrMaxRowsExceeded {
+		t.Fatalf("expected ErrMaxRowsExceeded, got %v", err)
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	SetMaxBytes(1024)
+	defer SetMaxBytes(0)
+
+	var samples []Sample
+	if err := UnmarshalString("foo,BAR\na,1\nb,2", &samples); err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 samples, got %d", len(samples))
+	}
+
+	in := &endlessCSV{}
+	if err := Unmarshal(in, &samples); err != ErrMaxBytesExceeded {
+		t.Fatalf("expected ErrMaxBytesExceeded, got %v", err)
+	}
+	if in.read > 1025 {
+		t.Fatalf("expected at most 1025 bytes read, got %d", in.read)
+	}
+}
+
+func TestUnmarshalParallel(t *testing.T) {
+	SetParallelism(4)
+	defer SetParallelism(1)
+
+	csvContent := "foo,BAR\n"
+	for i := 0; i < 100; i++ {
+		csvContent += "f" + strconv.Itoa(i) + "," + strconv.Itoa(i) + "\n"
+	}
+	var samples []Sample
+	if err := UnmarshalString(csvContent, &samples); err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 100 {
+		t.Fatalf("expected 100 samples, got %d", len(samples))
+	}
+	for i, sample := range samples {
+		if sample.Foo != "f"+strconv.Itoa(i) || sample.Bar != i {
+			t.Fatalf("unexpected sample %d: %+v", i, sample)
+		}
+	}
+
+	// The first failing row is reported, whatever the goroutine scheduling.
+	csvContent = "foo,BAR\na,1\nb,x\nc,3\nd,y\n"
+	err := UnmarshalString(csvContent, &samples)
+	parseErr, ok := err.(*csv.ParseError)
+	if !ok {
+		t.Fatalf("expected a *csv.ParseError, got %v", err)
+	}
+	if parseErr.Line != 3 {
+		t.Fatalf("expected an error on line 3, got %d", parseErr.Line)
+	}
+
+	// The warning handler is not called concurrently.
+	type small struct {
+		N uint8 `csv:"n"`
+	}
+	SetIntOverflowBehavior(IntOverflowClamp)
+	defer SetIntOverflowBehavior(IntOverflowError)
+	warnings := 0
+	SetIntOverflowWarningHandler(func(err *ConversionError) {
+		warnings++
+	})
+	defer SetIntOverflowWarningHandler(nil)
+	csvContent = "n\n" + strings.Repeat("300\n", 100)
+	var smalls []small
+	if err := UnmarshalString(csvContent, &smalls); err != nil {
+		t.Fatal(err)
+	}
+	if warnings != 100 {
+		t.Fatalf("expected 100 warnings, got %d", warnings)
+	}
+}
+
+type slowUnmarshaller struct {
+	val string
+}
+
+func (s *slowUnmarshaller) UnmarshalCSV(val string) error {
+	// Simulate an expensive conversion.
+	for i := 0; i < 200; i++ {
+		val = strings.TrimSpace(val)
+		_, _ = strconv.ParseFloat(val, 64)
+	}
+	s.val = val
+	return nil
+}
+
+func (s slowUnmarshaller) MarshalCSV() (string, error) {
+	return s.val, nil
+}
+
+type wideSample struct {
+	A, B, C, D, E, F, G, H slowUnmarshaller
+	I, J, K, L             float64
+	M, N, O, P             int
+}
+
+func BenchmarkUnmarshalParallelism(b *testing.B) {
+	var buf bytes.Buffer
+	buf.WriteString("A,B,C,D,E,F,G,H,I,J,K,L,M,N,O,P\n")
+	for i := 0; i < 1000; i++ {
+		buf.WriteString("1.5,2.5,3.5,4.5,5.5,6.5,7.5,8.5,1.25,2.25,3.25,4.25,1,2,3,4\n")
+	}
+	in := buf.Bytes()
+	for _, n := range []int{1, 2, 4, 8} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			SetParallelism(n)
+			defer SetParallelism(1)
+			for i := 0; i < b.N; i++ {
+				var out []wideSample
+				if err := UnmarshalBytes(in, &out); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestNilForEmptyRows(t *testing.T) {
+	SetNilForEmptyRows(true)
+	defer SetNilForEmptyRows(false)
+
+	csvContent := "foo,BAR\na,1\n,\n,2\n"
+	var samples []*Sample
+	if err := UnmarshalString(csvContent, &samples); err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 3 {
+		t.Fatalf("expected 3 samples, got %d", len(samples))
+	}
+	if samples[0] == nil || samples[0].Foo != "a" {
+		t.Fatalf("unexpected first sample %v", samples[0])
+	}
+	if samples[1] != nil {
+		t.Fatalf("expected nil for an empty row, got %v", samples[1])
+	}
+	if samples[2] == nil || samples[2].Bar != 2 {
+		t.Fatalf("expected a struct for a partially empty row, got %v", samples[2])
+	}
+
+	// Non pointer outputs still get zero valued structs.
+	var values []Sample
+	if err := UnmarshalString(csvContent, &values); err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 3 {
+		t.Fatalf("expected 3 samples, got %d", len(values))
+	}
+
+	c := make(chan *Sample)
+	go func() {
+		if err := UnmarshalStringToChan(csvContent, c); err != nil {
+			t.Error(err)
+		}
+	}()
+	samples = samples[:0]
+	for v := range c {
+		samples = append(samples, v)
+	}
+	if len(samples) != 3 || samples[1] != nil {
+		t.Fatalf("expected a nil second sample from the channel, got %v", samples)
+	}
+}
+
+func TestHeaderAliases(t *testing.T) {
+	SetHeaderAliases(map[string]string{
+		"Vendor Foo": "foo",
+		"bar_value":  "BAR",
+	})
+	defer SetHeaderAliases(nil)
+
+	var samples []Sample
+	if err := UnmarshalString("Vendor Foo,bar_value,Baz\na,1,b", &samples); err != nil {
+		t.Fatal(err)
+	}
+	expected := Sample{Foo: "a", Bar: 1, Baz: "b"}
+	if !reflect.DeepEqual(expected, samples[0]) {
+		t.Fatalf("expected sample %v, got %v", expected, samples[0])
+	}
+}
+
+func TestSkipEmptyRows(t *testing.T) {
+	SetSkipEmptyRows(true)
+	defer SetSkipEmptyRows(false)
+
+	csvContent := "foo,BAR\na,1\n,\nb,2\n , \n,\n"
+	var samples []Sample
+	if err := UnmarshalString(csvContent, &samples); err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 3 {
+		t.Fatalf("expected 3 samples, got %d", len(samples))
+	}
+	expected := []Sample{{Foo: "a", Bar: 1}, {}, {Foo: "b", Bar: 2}}
+	if !reflect.DeepEqual(expected, samples) {
+		t.Fatalf("expected samples %v, got %v", expected, samples)
+	}
+
+	c := make(chan Sample)
+	go func() {
+		if err := UnmarshalStringToChan(csvContent, c); err != nil {
+			t.Error(err)
+		}
+	}()
+	samples = samples[:0]
+	for v := range c {
+		samples = append(samples, v)
+	}
+	if !reflect.DeepEqual(expected, samples) {
+		t.Fatalf("expected samples %v from the channel, got %v", expected, samples)
+	}
+
+	// the blank records before a read error are not trailing
+	r := &trailingEmptyRowsCSVReader{CSVReader: csv.NewReader(strings.NewReader("a,1\n,\n\"bad\n"))}
+	for _, expected := range [][]string{{"a", "1"}, {"", ""}} {
+		if record, err := r.Read(); err != nil || !reflect.DeepEqual(expected, record) {
+			t.Fatalf("expected %q, got %q, %v", expected, record, err)
+		}
+	}
+	if _, err := r.Read(); err == nil || err == io.EOF {
+		t.Fatalf("expected the read error, got %v", err)
+	}
+}
+
+func TestSimpleDecoderWithHeaders(t *testing.T) {
+	headers := []string{"BAR", "foo"}
+	d := NewSimpleDecoderWithHeaders(headers, csv.NewReader(strings.NewReader("1,a\n2,b")))
+	var samples []Sample
+	if err := UnmarshalDecoder(d, &samples); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Sample{{Foo: "a", Bar: 1}, {Foo: "b", Bar: 2}}
+	if !reflect.DeepEqual(expected, samples) {
+		t.Fatalf("expected samples %v, got %v", expected, samples)
+	}
+
+	d = NewSimpleDecoderWithHeaders(headers, csv.NewReader(strings.NewReader("1,a\n2,b")))
+	c := make(chan Sample)
+	go func() {
+		if err := UnmarshalDecoderToChan(d, c); err != nil {
+			t.Error(err)
+		}
+	}()
+	samples = samples[:0]
+	for v := range c {
+		samples = append(samples, v)
+	}
+	if !reflect.DeepEqual(expected, samples) {
+		t.Fatalf("expected samples %v from the channel, got %v", expected, samples)
+	}
+}
+
+func TestComputedField(t *testing.T) {
+	type orderLine struct {
+		Quantity int     `csv:"quantity"`
+		Price    float64 `csv:"price"`
+		Total    float64 `csv:"total"`
+	}
+	RegisterComputedField("total", func(record []string, headerIndex map[string]int) (string, error) {
+		quantity, err := strconv.ParseFloat(record[headerIndex["quantity"]], 64)
+		if err != nil {
+			return "", err
+		}
+		price, err := strconv.ParseFloat(record[headerIndex["price"]], 64)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatFloat(quantity*price, 'f', -1, 64), nil
+	})
+	defer RegisterComputedField("total", nil)
+
+	var lines []orderLine
+	if err := UnmarshalString("quantity,price\n2,1.5\n4,0.25", &lines); err != nil {
+		t.Fatal(err)
+	}
+	expected := []orderLine{{2, 1.5, 3}, {4, 0.25, 1}}
+	if !reflect.DeepEqual(expected, lines) {
+		t.Fatalf("expected %v, got %v", expected, lines)
+	}
+
+	// The computed value wins over a total column.
+	if err := UnmarshalString("quantity,price,total\n2,1.5,100", &lines); err != nil {
+		t.Fatal(err)
+	}
+	if lines[0].Total != 3 {
+		t.Fatalf("expected a computed total of 3, got %v", lines[0].Total)
+	}
+
+	err := UnmarshalString("quantity,price\nx,1.5", &lines)
+	if parseErr, ok := err.(*csv.ParseError); !ok || parseErr.Line != 2 || parseErr.Column != 3 {
+		t.Fatalf("expected a *csv.ParseError on line 2, column 3, got %v", err)
+	}
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) {
+		t.Fatalf("expected the error of the computed field to be kept, got %v", err)
+	}
+	errNoPrice := errors.New("no price")
+	RegisterComputedField("total", func(record []string, headerIndex map[string]int) (string, error) {
+		return "", errNoPrice
+	})
+	err = UnmarshalString("quantity,price,total\n2,1.5,100", &lines)
+	if parseErr, ok := err.(*csv.ParseError); !ok || parseErr.Column != 3 || !errors.Is(err, errNoPrice) {
+		t.Fatalf("expected a *csv.ParseError wrapping errNoPrice in column 3, got %v", err)
+	}
+
+	type sized struct {
+		Name  string `csv:"name"`
+		Small uint8  `csv:"small"`
+	}
+	RegisterComputedField("small", func(record []string, headerIndex map[string]int) (string, error) {
+		return "300", nil
+	})
+	defer RegisterComputedField("small", nil)
+	var sizeds []sized
+	err = UnmarshalString("name\na\n", &sizeds)
+	var convErr *ConversionError
+	if !errors.As(err, &convErr) || convErr.Line != 2 || convErr.Column != "small" || !errors.Is(err, ErrIntOverflow) {
+		t.Fatalf("expected a ConversionError on line 2, got %v", err)
+	}
+	var warnings []*ConversionError
+	SetIntOverflowBehavior(IntOverflowClamp)
+	defer SetIntOverflowBehavior(IntOverflowError)
+	SetIntOverflowWarningHandler(func(err *ConversionError) {
+		warnings = append(warnings, err)
+	})
+	defer SetIntOverflowWarningHandler(nil)
+	if err := UnmarshalString("name\na\n", &sizeds); err != nil || sizeds[0].Small != 255 {
+		t.Fatalf("unexpected clamp %+v, %v", sizeds, err)
+	}
+	if len(warnings) != 1 || warnings[0].Line != 2 {
+		t.Errorf("expected a warning on line 2, got %+v", warnings)
+	}
+}
+
+func TestFailIfUnmatchedColumns(t *testing.T) {
+	FailIfUnmatchedColumns = true
+	defer func() {
+		FailIfUnmatchedColumns = false
+	}()
+
+	var samples []Sample
+	if err := UnmarshalString("foo,BAR\na,1", &samples); err != nil {
+		t.Fatal(err)
+	}
+	err := UnmarshalString("foo,BAR,extra,other\na,1,x,y", &samples)
+	if err == nil || err.Error() != "found unmatched csv columns [extra other]" {
+		t.Fatalf("expected an unmatched columns error, got %v", err)
+	}
+
+	c := make(chan Sample)
+	go func() {
+		for range c {
+		}
+	}()
+	if err := UnmarshalStringToChan("foo,extra\na,x", c); err == nil {
+		t.Fatal("expected an unmatched columns error from readEach")
+	}
+
+	if _, err := NewUnmarshaller(csv.NewReader(strings.NewReader("foo,extra\na,x")), Sample{}); err == nil {
+		t.Fatal("expected an unmatched columns error from NewUnmarshaller")
+	}
+}
+
+func TestIgnoredColumns(t *testing.T) {
+	FailIfUnmatchedColumns = true
+	SetIgnoredColumns("notes", "BAR")
+	defer func() {
+		FailIfUnmatchedColumns = false
+		SetIgnoredColumns()
+	}()
+
+	var samples []Sample
+	if err := UnmarshalString("foo,BAR,notes\na,1,long text", &samples); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Sample{{Foo: "a"}}
+	if !reflect.DeepEqual(expected, samples) {
+		t.Fatalf("expected %v, got %v", expected, samples)
+	}
+	if err := UnmarshalString("foo,notes,extra\na,x,y", &samples); err == nil || err.Error() != "found unmatched csv columns [extra]" {
+		t.Fatalf("expected only extra to be unmatched, got %v", err)
+	}
+
+	um, err := NewUnmarshaller(csv.NewReader(strings.NewReader("foo,notes\na,x")), Sample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := um.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if len(um.MismatchedHeaders) != 0 {
+		t.Errorf("expected no mismatched headers, got %v", um.MismatchedHeaders)
+	}
+}
+
+func TestUnmarshalAppend(t *testing.T) {
+	samples := []Sample{{Foo: "a", Bar: 1}}
+	if err := UnmarshalAppend(strings.NewReader("foo,BAR\nb,2\nc,3"), &samples); err != nil {
+		t.Fatal(err)
+	}
+	if err := UnmarshalAppend(strings.NewReader("foo,BAR\nd,4"), &samples); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Sample{{Foo: "a", Bar: 1}, {Foo: "b", Bar: 2}, {Foo: "c", Bar: 3}, {Foo: "d", Bar: 4}}
+	if !reflect.DeepEqual(expected, samples) {
+		t.Fatalf("expected %v, got %v", expected, samples)
+	}
+
+	if err := UnmarshalAppend(strings.NewReader("foo,BAR\ne,x"), &samples); err == nil {
+		t.Fatal("expected a conversion error")
+	}
+	if len(samples) != len(expected) {
+		t.Fatalf("expected nothing appended on error, got %v", samples)
+	}
+
+	if err := UnmarshalAppend(strings.NewReader("foo,BAR\nb,2"), samples); err == nil {
+		t.Fatal("expected an error for a non pointer slice")
+	}
+}
+
+func TestUnmarshalPreview(t *testing.T) {
+	var samples []Sample
+	if err := UnmarshalPreview(strings.NewReader("foo,BAR\na,1\nb,2\nc,3"), &samples, 2); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Sample{{Foo: "a", Bar: 1}, {Foo: "b", Bar: 2}}
+	if !reflect.DeepEqual(expected, samples) {
+		t.Fatalf("expected %v, got %v", expected, samples)
+	}
+
+	samples = nil
+	if err := UnmarshalPreview(strings.NewReader("foo,BAR\na,1"), &samples, 10); err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 1 {
+		t.Fatalf("expected 1 sample, got %d", len(samples))
+	}
+
+	// An endless input is only read up to the preview.
+	in := &endlessCSV{}
+	if err := UnmarshalPreview(in, &samples, 10); err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 10 {
+		t.Fatalf("expected 10 samples, got %d", len(samples))
+	}
+}
+
+func TestConversionError(t *testing.T) {
+	var samples []Sample
+	err := UnmarshalString("foo,BAR,Quux\na,1,1.5\nb,2,x", &samples)
+	var convErr *ConversionError
+	if !errors.As(err, &convErr) {
+		t.Fatalf("expected a *ConversionError, got %v", err)
+	}
+	if convErr.Line != 3 || convErr.Column != "Quux" || convErr.Value != "x" {
+		t.Fatalf("unexpected conversion error %+v", convErr)
+	}
+	if numErr, ok := convErr.Err.(*strconv.NumError); !ok || numErr.Func != "ParseFloat" {
+		t.Fatalf("expected a ParseFloat error, got %v", convErr.Err)
+	}
+
+	err = UnmarshalWithoutHeaders(strings.NewReader("a,x"), &samples)
+	if !errors.As(err, &convErr) {
+		t.Fatalf("expected a *ConversionError, got %v", err)
+	}
+	if convErr.Line != 1 || convErr.Column != "BAR" || convErr.Value != "x" {
+		t.Fatalf("unexpected conversion error %+v", convErr)
+	}
+
+	um, err := NewUnmarshaller(csv.NewReader(strings.NewReader("foo,BAR\na,x")), Sample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := um.Read(); !errors.As(err, &convErr) || convErr.Column != "BAR" {
+		t.Fatalf("expected a *ConversionError for column BAR, got %v", err)
+	}
+}
+
+func TestColumnValueDecoder(t *testing.T) {
+	newDecoder := func() SimpleDecoder {
+		values := [][]string{{"a", "1"}, {"b", "2"}}
+		return NewColumnValueDecoder([]string{"foo", "BAR"}, func() ([]string, bool) {
+			if len(values) == 0 {
+				return nil, false
+			}
+			row := values[0]
+			values = values[1:]
+			return row, true
+		})
+	}
+	expected := []Sample{{Foo: "a", Bar: 1}, {Foo: "b", Bar: 2}}
+
+	var samples []Sample
+	if err := UnmarshalDecoder(newDecoder(), &samples); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, samples) {
+		t.Fatalf("expected %v, got %v", expected, samples)
+	}
+
+	samples = samples[:0]
+	if err := UnmarshalDecoderToCallback(newDecoder(), func(s Sample) {
+		samples = append(samples, s)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, samples) {
+		t.Fatalf("expected %v from the callback, got %v", expected, samples)
+	}
+}
+
+type panickyField string
+
+func (p *panickyField) UnmarshalCSV(s string) error {
+	if s == "boom" {
+		panic("boom")
+	}
+	*p = panickyField(s)
+	return nil
+}
+
+func (p panickyField) MarshalCSV() (string, error) {
+	if p == "boom" {
+		panic("boom")
+	}
+	return string(p), nil
+}
+
+func TestRecoverFromPanics(t *testing.T) {
+	SetRecoverFromPanics(true)
+	defer SetRecoverFromPanics(false)
+
+	type panickySample struct {
+		Foo panickyField `csv:"foo"`
+	}
+	var samples []panickySample
+	err := Unmarshal(strings.NewReader("foo\nok\nboom\n"), &samples)
+	var parseErr *csv.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected a *csv.ParseError, got %v", err)
+	}
+	if parseErr.Line != 3 {
+		t.Fatalf("expected the error on line 3, got line %d", parseErr.Line)
+	}
+
+	if _, err := MarshalString([]panickySample{{Foo: "boom"}}); err == nil {
+		t.Fatal("expected an error from the panicking marshaller")
+	}
+
+	var withFields []panickyWithFields
+	if err := UnmarshalString("foo\nboom\n", &withFields); !errors.As(err, &parseErr) {
+		t.Errorf("expected a *csv.ParseError from UnmarshalCSVWithFields, got %v", err)
+	}
+
+	type multiSample struct {
+		Loc panickyMulti `csv:"loc"`
+	}
+	if _, err := MarshalString([]multiSample{{}}); err == nil {
+		t.Error("expected an error from the panicking MarshalCSVMulti")
+	}
+
+	e, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(ioutil.Discard)), panickySample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SetPreWriteHook(func(v interface{}) (bool, error) { panic("hook") })
+	if err := e.Encode(panickySample{Foo: "ok"}); err == nil || !strings.Contains(err.Error(), "SetPreWriteHook") {
+		t.Errorf("expected an error from the panicking hook, got %v", err)
+	}
+	e.SetPreWriteHook(nil)
+	e.SetFieldEncoder("foo", func(reflect.Value) (string, error) { panic("encoder") })
+	if err := e.Encode(panickySample{Foo: "ok"}); err == nil || !strings.Contains(err.Error(), "SetFieldEncoder") {
+		t.Errorf("expected an error from the panicking field encoder, got %v", err)
+	}
+
+	type computedSample struct {
+		Total int `csv:"total"`
+	}
+	RegisterComputedField("total", func(record []string, headerIndex map[string]int) (string, error) {
+		panic("computed")
+	})
+	defer RegisterComputedField("total", nil)
+	var computeds []computedSample
+	if err := UnmarshalString("foo\na\n", &computeds); err == nil || !strings.Contains(err.Error(), "RegisterComputedField") {
+		t.Errorf("expected an error from the panicking computed field, got %v", err)
+	}
+}
+
+type panickyWithFields struct {
+	Foo string `csv:"foo"`
+}
+
+func (p *panickyWithFields) UnmarshalCSVWithFields(key, value string) error {
+	panic("boom")
+}
+
+type panickyMulti struct{}
+
+func (panickyMulti) CSVHeaders() []string { return []string{"a", "b"} }
+
+func (panickyMulti) MarshalCSVMulti() ([]string, error) { panic("boom") }
+
+func TestRejectInvalidUTF8(t *testing.T) {
+	in := "foo,BAR\na,1\nb\xff,2"
+	var samples []Sample
+	if err := UnmarshalString(in, &samples); err != nil {
+		t.Fatal(err)
+	}
+
+	SetRejectInvalidUTF8(true)
+	defer SetRejectInvalidUTF8(false)
+
+	err := UnmarshalString(in, &samples)
+	var convErr *ConversionError
+	if !errors.As(err, &convErr) || !errors.Is(err, ErrInvalidUTF8) {
+		t.Fatalf("expected a *ConversionError wrapping ErrInvalidUTF8, got %v", err)
+	}
+	if convErr.Line != 3 || convErr.Column != "foo" {
+		t.Fatalf("expected line 3 column foo, got line %d column %s", convErr.Line, convErr.Column)
+	}
+
+	// Unmapped columns are checked too.
+	if err := UnmarshalString("foo,other\na,\xff", &samples); !errors.Is(err, ErrInvalidUTF8) {
+		t.Fatalf("expected ErrInvalidUTF8 for an unmapped column, got %v", err)
+	}
+
+	if err := UnmarshalStringToCallback(in, func(Sample) {}); !errors.Is(err, ErrInvalidUTF8) {
+		t.Fatalf("expected ErrInvalidUTF8 from readEach, got %v", err)
+	}
+
+	um, err := NewUnmarshaller(csv.NewReader(strings.NewReader(in)), Sample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := um.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := um.Read(); !errors.Is(err, ErrInvalidUTF8) {
+		t.Fatalf("expected ErrInvalidUTF8 from the Unmarshaller, got %v", err)
+	}
+}
+
+func TestAutoDetectDelimiter(t *testing.T) {
+	SetAutoDetectDelimiter(true)
+	defer SetAutoDetectDelimiter(false)
+
+	expected := []Sample{{Foo: "a,b", Bar: 1}, {Foo: "c", Bar: 2}}
+	for _, tc := range []struct {
+		in    string
+		comma rune
+	}{
+		{"foo,BAR\n\"a,b\",1\nc,2\n", ','},
+		{"foo;BAR\na,b;1\nc;2\n", ';'},
+		{"foo\tBAR\na,b\t1\nc\t2", '\t'},
+		{"foo|BAR\n\"a,b\"|1\nc|2\n", '|'},
+	} {
+		var samples []Sample
+		if err := UnmarshalString(tc.in, &samples); err != nil {
+			t.Fatalf("%q: %v", tc.in, err)
+		}
+		if !reflect.DeepEqual(expected, samples) {
+			t.Errorf("%q: expected %v, got %v", tc.in, expected, samples)
+		}
+		if DetectedDelimiter() != tc.comma {
+			t.Errorf("%q: expected %q to be detected, got %q", tc.in, tc.comma, DetectedDelimiter())
+		}
+	}
+}
+
+func TestTrimHeaders(t *testing.T) {
+	FailIfUnmatchedColumns = true
+	SetHeaderAliases(map[string]string{"Name": "foo"})
+	defer func() {
+		FailIfUnmatchedColumns = false
+		SetHeaderAliases(nil)
+		SetTrimHeaders(false)
+	}()
+
+	in := " Name ,BAR \n a ,1"
+	var samples []Sample
+	if err := UnmarshalString(in, &samples); err == nil {
+		t.Fatal("expected the padded alias to be unmatched")
+	}
+
+	SetTrimHeaders(true)
+	if err := UnmarshalString(in, &samples); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Sample{{Foo: " a ", Bar: 1}}
+	if !reflect.DeepEqual(expected, samples) {
+		t.Fatalf("expected %v, got %v", expected, samples)
+	}
+}
+
+func TestHeaderPrefixStrip(t *testing.T) {
+	SetHeaderPrefixStrip("fld_")
+	defer SetHeaderPrefixStrip("")
+
+	var samples []Sample
+	if err := UnmarshalString("fld_foo,BAR,fld_Baz\na,1,b", &samples); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Sample{{Foo: "a", Bar: 1, Baz: "b"}}
+	if !reflect.DeepEqual(expected, samples) {
+		t.Fatalf("expected %v, got %v", expected, samples)
+	}
+}
+
+func TestDuplicateHeaderSuffix(t *testing.T) {
+	SetDuplicateHeaderStrategy(DuplicateHeaderSuffix)
+	defer SetDuplicateHeaderStrategy(DuplicateHeaderKeep)
+	type contact struct {
+		Name   string `csv:"name"`
+		Name2  string `csv:"name_2"`
+		Name3  string `csv:"name_3"`
+		Phone  string `csv:"phone"`
+		Phone3 string `csv:"phone_3"`
+	}
+	var contacts []contact
+	// the second phone is phone_3, phone_2 being a header already
+	in := "name,phone,name,phone_2,name,phone\nann,1,bob,2,cid,3\n"
+	if err := UnmarshalString(in, &contacts); err != nil {
+		t.Fatal(err)
+	}
+	expected := []contact{{Name: "ann", Name2: "bob", Name3: "cid", Phone: "1", Phone3: "3"}}
+	if !reflect.DeepEqual(expected, contacts) {
+		t.Fatalf("expected %v, got %v", expected, contacts)
+	}
+}
+
+func TestUnmarshalNilInterfaceFields(t *testing.T) {
+	type row struct {
+		TypeMarshaller `csv:"embedded"`
+		Value          TypeMarshaller `csv:"value"`
+	}
+	for _, in := range []string{"embedded\nx\n", "value\nx\n"} {
+		var rows []row
+		err := UnmarshalString(in, &rows)
+		var noFunc NoUnmarshalFuncError
+		if !errors.As(err, &noFunc) {
+			t.Errorf("expected a NoUnmarshalFuncError for %q, got %v", in, err)
+		}
+	}
+}
+
+func TestUnmarshalTransform(t *testing.T) {
+	type product struct {
+		Code  string `csv:"code,transform:upper"`
+		Email string `csv:"email,transform:lower"`
+		Name  string `csv:"name,transform:title"`
+		Note  string `csv:"note,transform:trim"`
+	}
+	var products []product
+	if err := UnmarshalString("code,email,name,note\nab-1,Ann@Example.COM,red apple,  fresh  \n", &products); err != nil {
+		t.Fatal(err)
+	}
+	expected := []product{{Code: "AB-1", Email: "ann@example.com", Name: "Red Apple", Note: "fresh"}}
+	if !reflect.DeepEqual(expected, products) {
+		t.Fatalf("expected %v, got %v", expected, products)
+	}
+	if err := UnmarshalString("code,email,name,note\na,b,\"émile  o'neil jean-luc\tvAN\",c\n", &products); err != nil {
+		t.Fatal(err)
+	}
+	if products[0].Name != "Émile  O'neil Jean-luc\tVAN" {
+		t.Fatalf("expected only the first letter of each word upper-cased, got %q", products[0].Name)
+	}
+
+	type unknown struct {
+		Code string `csv:"code,transform:reverse"`
+	}
+	var unknowns []unknown
+	if err := UnmarshalString("code\nab\n", &unknowns); err == nil || !strings.Contains(err.Error(), `unknown transform "reverse"`) {
+		t.Fatalf("expected an unknown transform error, got %v", err)
+	}
+}
+
+func TestUnmarshalGrouped(t *testing.T) {
+	type line struct {
+		Product string `csv:"product"`
+		Qty     int    `csv:"qty"`
+	}
+	type order struct {
+		ID       string `csv:"order"`
+		Customer string `csv:"customer"`
+		Lines    []line
+	}
+	in := "order,customer,product,qty\n1,ann,apple,2\n1,ann,pear,1\n2,bob,fig,5\n1,ann,kiwi,3\n"
+	var orders []order
+	if err := UnmarshalGrouped(strings.NewReader(in), &orders, "order"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []order{
+		{ID: "1", Customer: "ann", Lines: []line{{"apple", 2}, {"pear", 1}}},
+		{ID: "2", Customer: "bob", Lines: []line{{"fig", 5}}},
+		{ID: "1", Customer: "ann", Lines: []line{{"kiwi", 3}}},
+	}
+	if !reflect.DeepEqual(expected, orders) {
+		t.Fatalf("expected %v, got %v", expected, orders)
+	}
+
+	var ptrs []*order
+	if err := UnmarshalGrouped(strings.NewReader(in), &ptrs, "order"); err != nil {
+		t.Fatal(err)
+	}
+	if len(ptrs) != 3 || len(ptrs[0].Lines) != 2 {
+		t.Fatalf("unexpected grouping of pointers %v", ptrs)
+	}
+
+	err := UnmarshalGrouped(strings.NewReader(in+"3,cid,plum,x\n"), &orders, "order")
+	var parseErr *csv.ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 6 {
+		t.Fatalf("expected a parse error on line 6, got %v", err)
+	}
+	if err := UnmarshalGrouped(strings.NewReader(in), &orders, "missing"); err == nil {
+		t.Fatal("expected an error for a missing key column")
+	}
+	var samples []Sample
+	if err := UnmarshalGrouped(strings.NewReader(in), &samples, "order"); err == nil {
+		t.Fatal("expected an error for a struct without slice of struct field")
+	}
+}
+
+func TestMaxFieldLength(t *testing.T) {
+	SetMaxFieldLength(5)
+	defer SetMaxFieldLength(0)
+
+	var samples []Sample
+	if err := UnmarshalString("foo,BAR,unmapped\nabcde,1,much longer\n", &samples); err != nil {
+		t.Fatal(err)
+	}
+	err := UnmarshalString("foo,BAR\nabcdef,1\n", &samples)
+	var convErr *ConversionError
+	if !errors.As(err, &convErr) || !errors.Is(err, ErrMaxFieldLengthExceeded) || convErr.Column != "foo" {
+		t.Fatalf("expected a *ConversionError of column foo wrapping ErrMaxFieldLengthExceeded, got %v", err)
+	}
+
+	// Custom unmarshallers are guarded as well.
+	var custom []struct {
+		Foo panickyField `csv:"foo"`
+	}
+	if err := UnmarshalString("foo\nabcdef\n", &custom); !errors.Is(err, ErrMaxFieldLengthExceeded) {
+		t.Fatalf("expected ErrMaxFieldLengthExceeded for a custom unmarshaller, got %v", err)
+	}
+}
+
+func TestHeaderSearchDepth(t *testing.T) {
+	in := "Sales report\nGenerated,2020-01-02\nfoo,BAR,Baz\na,1,x\nb,2,y\n"
+	var samples []Sample
+	if err := UnmarshalString(in, &samples); err == nil {
+		t.Fatal("expected an error without header search")
+	}
+
+	SetHeaderSearchDepth(5)
+	defer SetHeaderSearchDepth(1)
+	samples = nil
+	if err := UnmarshalString(in, &samples); err != nil {
+		t.Fatal(err)
+	}
+	expected := []Sample{{Foo: "a", Bar: 1, Baz: "x"}, {Foo: "b", Bar: 2, Baz: "y"}}
+	if !reflect.DeepEqual(expected, samples) {
+		t.Fatalf("expected %v, got %v", expected, samples)
+	}
+
+	var fromCallback []Sample
+	if err := UnmarshalStringToCallback(in, func(s Sample) {
+		fromCallback = append(fromCallback, s)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, fromCallback) {
+		t.Fatalf("expected %v from readEach, got %v", expected, fromCallback)
+	}
+
+	// Lines are counted from the start of the CSV.
+	err := UnmarshalString("title\nfoo,BAR\na,x\n", &samples)
+	var parseErr *csv.ParseError
+	if !errors.As(err, &parseErr) || parseErr.Line != 3 {
+		t.Fatalf("expected a parse error on line 3, got %v", err)
+	}
+
+	SetHeaderMinMatches(2)
+	defer SetHeaderMinMatches(1)
+	if err := UnmarshalString("title\nfoo,other\na,b\n", &samples); err == nil {
+		t.Fatal("expected an error when no line matches enough struct fields")
+	}
+}
+
+func TestSetLazyQuotes(t *testing.T) {
+	type note struct {
+		Title string `csv:"title"`
+		Body  string `csv:"body"`
+	}
+	in := "title,body\n5\" screen,\"says \"hi\" twice\"\nmulti,\"line one\nline two\"\n"
+	var notes []note
+	if err := UnmarshalString(in, &notes); err == nil {
+		t.Fatal("expected an error for lazily quoted cells without SetLazyQuotes")
+	}
+
+	SetLazyQuotes(true)
+	defer SetLazyQuotes(false)
+	notes = nil
+	if err := UnmarshalString(in, &notes); err != nil {
+		t.Fatal(err)
+	}
+	expected := []note{{Title: "5\" screen", Body: "says \"hi\" twice"}, {Title: "multi", Body: "line one\nline two"}}
+	if !reflect.DeepEqual(notes, expected) {
+		t.Errorf("expected %q, got %q", expected, notes)
+	}
+}
+
+func TestUnmarshalInto(t *testing.T) {
+	type user struct {
+		ID    int    `csv:"id"`
+		Name  string `csv:"name"`
+		Notes string `csv:"-"`
+	}
+	users := []user{{ID: 1, Name: "old", Notes: "kept"}, {ID: 2, Notes: "also kept"}}
+	first := &users[0]
+	if err := UnmarshalInto(strings.NewReader("name\nalice\nbob\n"), users); err != nil {
+		t.Fatal(err)
+	}
+	expected := []user{{ID: 1, Name: "alice", Notes: "kept"}, {ID: 2, Name: "bob", Notes: "also kept"}}
+	if !reflect.DeepEqual(users, expected) {
+		t.Errorf("expected %+v, got %+v", expected, users)
+	}
+	if first != &users[0] {
+		t.Error("expected the existing slice to be reused")
+	}
+
+	pointers := []*user{{ID: 1}, nil}
+	if err := UnmarshalInto(strings.NewReader("name\nalice\nbob\n"), pointers); err != nil {
+		t.Fatal(err)
+	}
+	if pointers[0].ID != 1 || pointers[0].Name != "alice" || pointers[1].Name != "bob" {
+		t.Errorf("unexpected users %+v %+v", pointers[0], pointers[1])
+	}
+
+	if err := UnmarshalInto(strings.NewReader("name\nalice\n"), users); err == nil {
+		t.Error("expected an error for fewer rows than elements")
+	}
+}
+
+func TestUnmarshalWithCoercionReport(t *testing.T) {
+	type reading struct {
+		Value float64 `csv:"value"`
+		Unit  string  `csv:"unit,default=kg"`
+	}
+	in := "value,unit\n1.5,g\n3,\n,lb\nn/a,\n"
+	var readings []reading
+	report, err := UnmarshalWithCoercionReport(strings.NewReader(in), func(*csv.ParseError) bool { return true }, &readings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readings) != 4 || readings[1].Value != 3 || readings[1].Unit != "kg" {
+		t.Errorf("unexpected readings %+v", readings)
+	}
+	if c := *report.Columns["value"]; c != (ColumnCoercion{Parsed: 2, Empty: 1, Fallback: 1}) {
+		t.Errorf("unexpected value counts %+v", c)
+	}
+	if c := *report.Columns["unit"]; c != (ColumnCoercion{Parsed: 2, Defaulted: 2}) {
+		t.Errorf("unexpected unit counts %+v", c)
+	}
+
+	if _, err := UnmarshalWithCoercionReport(strings.NewReader(in), nil, &readings); err == nil {
+		t.Error("expected an error without an error handler")
+	}
+}
+
+func TestUnmarshalTSV(t *testing.T) {
+	type item struct {
+		Name string `csv:"name"`
+		Size string `csv:"size"`
+	}
+	var items []item
+	if err := UnmarshalTSV(strings.NewReader("name\tsize\nscrew, small\t3\"\n"), &items); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0] != (item{"screw, small", "3\""}) {
+		t.Fatalf("unexpected items %+v", items)
+	}
+
+	b := bytes.Buffer{}
+	if err := MarshalTSV(items, &b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "name\tsize\nscrew, small\t\"3\"\"\"\n" {
+		t.Errorf("unexpected tsv %q", b.String())
+	}
+	items = nil
+	if err := UnmarshalTSV(&b, &items); err != nil || items[0].Size != "3\"" {
+		t.Errorf("unexpected round trip %+v, %v", items, err)
+	}
+}
+
+func TestUnmarshalToCallbackWithMask(t *testing.T) {
+	type record struct {
+		ID    int    `csv:"id"`
+		Name  string `csv:"name"`
+		Score int    `csv:"score"`
+	}
+	var records []record
+	var masks [][]bool
+	err := UnmarshalToCallbackWithMask(strings.NewReader("score,id,name\n0,1,\n,2,bob\n"), func(r record, populated []bool) {
+		records = append(records, r)
+		masks = append(masks, populated)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1] != (record{2, "bob", 0}) {
+		t.Fatalf("unexpected records %+v", records)
+	}
+	if !reflect.DeepEqual(masks, [][]bool{{true, false, true}, {true, true, false}}) {
+		t.Errorf("unexpected masks %v", masks)
+	}
+
+	if err := UnmarshalToCallbackWithMask(strings.NewReader("id\n1\n"), func(r record) {}); err == nil {
+		t.Error("expected an error for a func without mask")
+	}
+	// the cells set with SetEmptyValues are empty
+	SetEmptyValues([]string{"NULL"})
+	defer SetEmptyValues(nil)
+	masks = nil
+	if err := UnmarshalToCallbackWithMask(strings.NewReader("id,name\nNULL,x\n"), func(r record, populated []bool) {
+		masks = append(masks, populated)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(masks, [][]bool{{false, true, false}}) {
+		t.Errorf("unexpected masks with empty values %v", masks)
+	}
+}
+
+func TestSetQuoteChar(t *testing.T) {
+	type note struct {
+		Title string `csv:"title"`
+		Body  string `csv:"body"`
+	}
+	SetQuoteChar('\'')
+	defer SetQuoteChar('"')
+
+	var notes []note
+	in := "title,body\n'a, b','it''s \"quoted\"'\nplain,\"x\n"
+	if err := UnmarshalString(in, &notes); err != nil {
+		t.Fatal(err)
+	}
+	expected := []note{{"a, b", "it's \"quoted\""}, {"plain", "\"x"}}
+	if !reflect.DeepEqual(notes, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, notes)
+	}
+
+	out, err := MarshalString(notes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "title,body\n'a, b','it''s \"quoted\"'\nplain,\"x\n" {
+		t.Errorf("unexpected csv %q", out)
+	}
+}
+
+func TestColumnIndexTag(t *testing.T) {
+	defer func(fail bool) { FailIfDoubleHeaderNames = fail }(FailIfDoubleHeaderNames)
+	FailIfDoubleHeaderNames = false
+	type reading struct {
+		First  string `csv:"value,index:1"`
+		Second string `csv:"value"`
+		Unit   string `csv:"unit,index:3"`
+	}
+	in := "id,value,value,unit\n1,a,b,kg\n"
+	var readings []reading
+	if err := UnmarshalString(in, &readings); err != nil {
+		t.Fatal(err)
+	}
+	if readings[0] != (reading{"a", "b", "kg"}) {
+		t.Errorf("unexpected reading %+v", readings[0])
+	}
+
+	readings = nil
+	if err := UnmarshalToCallback(strings.NewReader("id,x,value,y\n1,a,b,kg\n"), func(r reading) {
+		readings = append(readings, r)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if readings[0] != (reading{"a", "b", "kg"}) {
+		t.Errorf("unexpected reading from a callback %+v", readings[0])
+	}
+
+	um, err := NewUnmarshaller(csv.NewReader(strings.NewReader(in)), reading{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj, err := um.Read(); err != nil || obj.(reading) != (reading{"a", "b", "kg"}) {
+		t.Errorf("unexpected reading from an unmarshaller %+v, %v", obj, err)
+	}
+
+	type badIndex struct {
+		A string `csv:"a,index:x"`
+	}
+	var bad []badIndex
+	if err := UnmarshalString("a\n1\n", &bad); err == nil || !strings.Contains(err.Error(), "invalid column index") {
+		t.Errorf("expected an invalid column index error, got %v", err)
+	}
+	type negativeIndex struct {
+		A string `csv:"a,index:-1"`
+	}
+	var negative []negativeIndex
+	if err := UnmarshalString("a\n1\n", &negative); err == nil || !strings.Contains(err.Error(), "invalid column index") {
+		t.Errorf("expected an invalid column index error, got %v", err)
+	}
+}
+
+func TestTransform(t *testing.T) {
+	type order struct {
+		ID    int     `csv:"id"`
+		Price float64 `csv:"price"`
+	}
+	type line struct {
+		ID    int     `csv:"order_id"`
+		Total float64 `csv:"total"`
+	}
+	b := bytes.Buffer{}
+	writer := NewSafeCSVWriter(csv.NewWriter(&b))
+	err := Transform(strings.NewReader("id,price\n1,2.5\n2,0\n3,4\n"), writer, order{}, func(v interface{}) (interface{}, error) {
+		o := v.(order)
+		if o.Price == 0 {
+			return nil, nil
+		}
+		return &line{ID: o.ID, Total: o.Price * 2}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "order_id,total\n1,5\n3,8\n" {
+		t.Fatalf("unexpected csv %q", b.String())
+	}
+
+	stop := errors.New("stop")
+	err = Transform(strings.NewReader("id,price\n1,2.5\n"), writer, &order{}, func(v interface{}) (interface{}, error) {
+		if v.(*order).ID != 1 {
+			t.Errorf("unexpected order %+v", v)
+		}
+		return nil, stop
+	})
+	if err != stop {
+		t.Errorf("expected the error of f, got %v", err)
+	}
+}
+
+type nestingNode struct {
+	Name     string        `csv:"name"`
+	Children []nestingNode `csv:"children" csv[]:"1"`
+}
+
+type treeNode struct {
+	Name     string     `csv:"name"`
+	Children []treeNode `csv:"children"`
+}
+
+func TestSetMaxNestingDepth(t *testing.T) {
+	var nodes []nestingNode
+	if err := UnmarshalString("name\na\n", &nodes); !errors.Is(err, ErrMaxNestingDepthExceeded) {
+		t.Errorf("expected ErrMaxNestingDepthExceeded for a self-nesting type, got %v", err)
+	}
+	if _, err := MarshalString([]nestingNode{{Name: "a"}}); !errors.Is(err, ErrMaxNestingDepthExceeded) {
+		t.Errorf("expected ErrMaxNestingDepthExceeded on encode, got %v", err)
+	}
+	// the element struct of an unexpanded slice is not walked
+	var trees []treeNode
+	if err := UnmarshalString("name\na\n", &trees); err != nil || trees[0].Name != "a" {
+		t.Errorf("unexpected decode %+v, %v", trees, err)
+	}
+
+	type inner struct {
+		Value int `csv:"value"`
+	}
+	type middle struct {
+		Inner inner `csv:"inner"`
+	}
+	type outer struct {
+		Middle *middle `csv:"middle"`
+	}
+	SetMaxNestingDepth(2)
+	defer SetMaxNestingDepth(0)
+	var outers []outer
+	if err := UnmarshalString("middle.inner.value\n1\n", &outers); !errors.Is(err, ErrMaxNestingDepthExceeded) {
+		t.Errorf("expected ErrMaxNestingDepthExceeded beyond 2 levels, got %v", err)
+	}
+	SetMaxNestingDepth(3)
+	if err := UnmarshalString("middle.inner.value\n1\n", &outers); err != nil || outers[0].Middle.Inner.Value != 1 {
+		t.Errorf("unexpected decode %+v, %v", outers, err)
+	}
+}
+
+func TestSetUnmatchedColumnHandler(t *testing.T) {
+	type sample struct {
+		A string `csv:"a"`
+	}
+	var unmatched []string
+	SetUnmatchedColumnHandler(func(header string) { unmatched = append(unmatched, header) })
+	defer SetUnmatchedColumnHandler(nil)
+
+	var samples []sample
+	if err := UnmarshalString("x,a,y\n1,2,3\n4,5,6\n", &samples); err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 || samples[1].A != "5" {
+		t.Errorf("unexpected samples %+v", samples)
+	}
+	if !reflect.DeepEqual(unmatched, []string{"x", "y"}) {
+		t.Errorf("expected the handler to be called once for x and y, got %v", unmatched)
+	}
+
+	unmatched = nil
+	FailIfUnmatchedColumns = true
+	defer func() { FailIfUnmatchedColumns = false }()
+	if err := UnmarshalString("x,a\n1,2\n", &samples); err == nil {
+		t.Error("expected an error with FailIfUnmatchedColumns")
+	}
+	if !reflect.DeepEqual(unmatched, []string{"x"}) {
+		t.Errorf("expected the handler to be called for x, got %v", unmatched)
+	}
+}
+
+func TestSetPartialResults(t *testing.T) {
+	type sample struct {
+		N int `csv:"n"`
+	}
+	in := "n\n1\n2\nthree\n4\n"
+	var samples []sample
+	if err := UnmarshalString(in, &samples); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	SetPartialResults(true)
+	defer SetPartialResults(false)
+	for _, workers := range []int{1, 3} {
+		SetParallelism(workers)
+		samples = nil
+		if err := UnmarshalString(in, &samples); err == nil {
+			t.Fatal("expected an error")
+		}
+		if !reflect.DeepEqual(samples, []sample{{1}, {2}}) {
+			t.Errorf("expected the rows before the failing one with %d workers, got %+v", workers, samples)
+		}
+	}
+	SetParallelism(1)
+
+	// a header error leaves no row decoded
+	FailIfDoubleHeaderNames = true
+	defer func() { FailIfDoubleHeaderNames = false }()
+	type pair struct {
+		A int `csv:"a"`
+	}
+	var pairs []pair
+	if err := UnmarshalString("a,a\n1,2\n3,4\n", &pairs); err == nil {
+		t.Fatal("expected an error for the double header")
+	}
+	if len(pairs) != 0 {
+		t.Errorf("expected no rows after a header error, got %+v", pairs)
+	}
+}
+
+func TestSetEmptyValues(t *testing.T) {
+	type sample struct {
+		Name  string   `csv:"name"`
+		Count int      `csv:"count"`
+		Score *float64 `csv:"score,omitempty"`
+		Level string   `csv:"level,default=low"`
+	}
+	in := "name,count,score,level\nNULL,N/A,-,null\na,1,2.5,high\n"
+	var samples []sample
+	if err := UnmarshalString(in, &samples); err == nil {
+		t.Fatal("expected an error for N/A in an int field")
+	}
+
+	SetEmptyValues([]string{"NULL", "N/A", "-"})
+	defer SetEmptyValues(nil)
+	if err := UnmarshalString(in, &samples); err != nil {
+		t.Fatal(err)
+	}
+	if samples[0].Name != "" || samples[0].Count != 0 || samples[0].Score != nil || samples[0].Level != "null" {
+		t.Errorf("unexpected first sample %+v", samples[0])
+	}
+	if samples[1].Name != "a" || samples[1].Count != 1 || *samples[1].Score != 2.5 {
+		t.Errorf("unexpected second sample %+v", samples[1])
+	}
+
+	SetEmptyValuesIgnoreCase(true)
+	defer SetEmptyValuesIgnoreCase(false)
+	if err := UnmarshalString(in, &samples); err != nil {
+		t.Fatal(err)
+	}
+	if samples[0].Level != "low" {
+		t.Errorf("expected the default for a case-insensitive empty value, got %q", samples[0].Level)
+	}
+	type computed struct {
+		Name  string `csv:"name"`
+		Grade string `csv:"grade,default=none"`
+	}
+	RegisterComputedField("grade", func(record []string, headerIndex map[string]int) (string, error) {
+		return "n/a", nil
+	})
+	defer RegisterComputedField("grade", nil)
+	var computeds []computed
+	if err := UnmarshalString("name\na\n", &computeds); err != nil {
+		t.Fatal(err)
+	}
+	if computeds[0].Grade != "none" {
+		t.Errorf("expected the default for an empty computed value, got %q", computeds[0].Grade)
+	}
+}
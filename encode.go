@@ -1,9 +1,18 @@
 package gocsv
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 type encoder struct {
@@ -14,6 +23,558 @@ func newEncoder(out io.Writer) *encoder {
 	return &encoder{out}
 }
 
+// Encoder writes values of a single struct type as CSV rows. The struct
+// info is computed once, when the Encoder is created. An Encoder reuses its row
+// buffer across calls, so it must not be used by several goroutines at once: give
+// each goroutine its own Clone.
+type Encoder struct {
+	writer     CSVWriter
+	inType     reflect.Type
+	structInfo *structInfo
+	columns    []encoderColumn // in the order they are written
+	row        []string
+
+	fieldEncoders map[string]func(reflect.Value) (string, error)
+	redactor      func(col int, key, value string) string
+	preWriteHook  func(v interface{}) (skip bool, err error)
+
+	writeHeader    bool // before the first row, set with SetWriteHeader
+	headerWritten  bool
+	headerKeyIndex int // of the tag key written in the header, set with SetHeaderKeyIndex
+
+	rows, fields int // written by Encode, EncodeAll and EncodeMap, see Stats
+
+	multi multiCells // of the row being encoded
+
+	view string // set with SetView
+
+	descriptions map[string]string // of the columns by header, set with SetColumnDescriptions
+}
+
+// encoderColumn is a field of the Encoder type, with what is needed to convert it
+// computed ahead, so that Encode does not walk the struct info for each row.
+type encoderColumn struct {
+	fieldInfo fieldInfo
+	direct    bool                                // no pointer, slice or array along the index chain
+	format    func(reflect.Value) string          // set for fields of a builtin type
+	encode    func(reflect.Value) (string, error) // set with SetFieldEncoder
+}
+
+// NewEncoder creates an Encoder writing to writer for the type of sample,
+// which must be a struct or a pointer to a struct.
+func NewEncoder(writer CSVWriter, sample interface{}) (*Encoder, error) {
+	inType := reflect.TypeOf(sample)
+	if inType == nil {
+		return nil, fmt.Errorf("cannot use nil sample, only struct supported")
+	}
+	if inType.Kind() == reflect.Ptr {
+		inType = inType.Elem()
+	}
+	if err := ensureInInnerType(inType); err != nil {
+		return nil, err
+	}
+	structInfo := getStructInfo(inType)
+	if structInfo.err != nil {
+		return nil, structInfo.err
+	}
+	if err := unsupportedFields(inType, structInfo, true); err != nil {
+		return nil, err
+	}
+	e := &Encoder{
+		writer:     writer,
+		inType:     inType,
+		structInfo: structInfo,
+	}
+	e.SetView("")
+	return e, nil
+}
+
+// SetView sets the columns to the fields written in view, those tagged with it, like
+// `csv:"ssn,views:admin"` or `csv:"ssn,views:admin|support"`, and those without the
+// views tag option. The empty view, the default, writes every field. The columns are
+// in the struct order again, even after ReverseColumns.
+func (e *Encoder) SetView(view string) {
+	e.view = view
+	e.columns = e.columns[:0]
+	for _, fieldInfo := range e.structInfo.Fields {
+		if !fieldInfo.inView(view) {
+			continue
+		}
+		column := newEncoderColumn(e.inType, fieldInfo)
+		column.encode = e.getFieldEncoder(&column.fieldInfo)
+		e.columns = append(e.columns, column)
+	}
+	e.row = make([]string, len(e.columns))
+}
+
+// callEncode calls the function set with SetFieldEncoder, recovering from its panics
+// when set with SetRecoverFromPanics.
+func (c *encoderColumn) callEncode(field reflect.Value) (value string, err error) {
+	if recoverFromPanics {
+		defer recoverCallbackPanic("SetFieldEncoder", &err)
+	}
+	return c.encode(field)
+}
+
+func newEncoderColumn(inType reflect.Type, fieldInfo fieldInfo) encoderColumn {
+	column := encoderColumn{fieldInfo: fieldInfo, direct: true}
+	t := inType
+	for _, i := range fieldInfo.IndexChain {
+		if t.Kind() != reflect.Struct {
+			column.direct = false
+			return column
+		}
+		t = t.Field(i).Type
+	}
+	if !fieldInfo.multiColumn && !fieldInfo.jsonArray && !fieldInfo.json && fieldInfo.separator == "" && !fieldInfo.hasBoolStrings() && !fieldInfo.percent && !fieldInfo.iso8601 && fieldInfo.currencySym == "" && !fieldInfo.group && !fieldInfo.presence {
+		column.format = builtinFormatter(t)
+	}
+	return column
+}
+
+// builtinFormatter returns a function converting a value of t like getFieldAsString,
+// without boxing it in an interface, or nil when t is not a builtin type.
+func builtinFormatter(t reflect.Type) func(reflect.Value) string {
+	if t.PkgPath() != "" {
+		return nil // a defined type, which may have marshal methods
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return reflect.Value.String
+	case reflect.Bool:
+		return func(v reflect.Value) string { return formatBool(v.Bool()) }
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(v reflect.Value) string { return strconv.FormatInt(v.Int(), 10) }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(v reflect.Value) string { return strconv.FormatUint(v.Uint(), 10) }
+	case reflect.Float32:
+		return func(v reflect.Value) string { return formatFloat(v.Float(), 32) }
+	case reflect.Float64:
+		return func(v reflect.Value) string { return formatFloat(v.Float(), 64) }
+	}
+	return nil
+}
+
+// SetTagSeparator sets the separator of the keys and options in the tags of the Encoder
+// type, in place of the package TagSeparator, so that encoders of types tagged with
+// different separators can be used at the same time. The columns are built again, in
+// the current view. The empty separator restores TagSeparator. When the tags split with
+// sep are invalid, the error is returned and the columns are left as is.
+func (e *Encoder) SetTagSeparator(sep string) error {
+	if sep == "" {
+		sep = TagSeparator
+	}
+	structInfo := getStructInfoWithSeparator(e.inType, sep)
+	if structInfo.err != nil {
+		return structInfo.err
+	}
+	if err := unsupportedFields(e.inType, structInfo, true); err != nil {
+		return err
+	}
+	e.structInfo = structInfo
+	e.SetView(e.view)
+	return nil
+}
+
+// callPreWriteHook calls the function set with SetPreWriteHook, recovering from its
+// panics when set with SetRecoverFromPanics.
+func (e *Encoder) callPreWriteHook(v interface{}) (skip bool, err error) {
+	if recoverFromPanics {
+		defer recoverCallbackPanic("SetPreWriteHook", &err)
+	}
+	return e.preWriteHook(v)
+}
+
+// SetFieldEncoder sets the function converting the field with the structKey tag to
+// its cell, in place of the default conversion. The function is not called when the
+// field cannot be reached, e.g. through a nil pointer, and the cell is left empty.
+// A nil function restores the default conversion.
+func (e *Encoder) SetFieldEncoder(structKey string, f func(reflect.Value) (string, error)) {
+	if e.fieldEncoders == nil {
+		e.fieldEncoders = make(map[string]func(reflect.Value) (string, error))
+	}
+	if f == nil {
+		delete(e.fieldEncoders, normalizeName(structKey))
+	} else {
+		e.fieldEncoders[normalizeName(structKey)] = f
+	}
+	for i := range e.columns {
+		e.columns[i].encode = e.getFieldEncoder(&e.columns[i].fieldInfo)
+	}
+}
+
+func (e *Encoder) getFieldEncoder(fieldInfo *fieldInfo) func(reflect.Value) (string, error) {
+	for _, key := range fieldInfo.keys {
+		if f, ok := e.fieldEncoders[key]; ok {
+			return f
+		}
+	}
+	return nil
+}
+
+// SetRedactor sets a function called with each cell before the row is written, with
+// the index of its column, the first key of the field tag and the converted value,
+// and returning the cell written, e.g. *** for a column a policy hides. The header is
+// not redacted. A nil function writes the cells as converted.
+func (e *Encoder) SetRedactor(f func(col int, key, value string) string) {
+	e.redactor = f
+}
+
+// SetPreWriteHook sets a function called by Encode and EncodeAll with a pointer to each
+// struct before it is written, e.g. to check its required fields. It may change the
+// struct, which is then a copy unless a pointer was encoded, return skip to leave out
+// its row, or return an error to stop the encode with it.
+func (e *Encoder) SetPreWriteHook(f func(v interface{}) (skip bool, err error)) {
+	e.preWriteHook = f
+}
+
+// SetWriteHeader sets whether Encode and EncodeAll write the header before the first
+// row, like Marshal, so that WriteHeader need not be called. It is false by default,
+// like for MarshalWithoutHeaders, and the header is not written twice if WriteHeader
+// is called anyway.
+func (e *Encoder) SetWriteHeader(b bool) {
+	e.writeHeader = b
+}
+
+// Clone returns a copy of the Encoder, with its columns, options and writer, but its
+// own row buffer, so that the copy and the Encoder can be used by different goroutines.
+// Give each its own writer with Reset, or share a SafeCSVWriter. The header is not
+// written again if the Encoder wrote it, and the Stats of the copy start from zero.
+func (e *Encoder) Clone() *Encoder {
+	c := *e
+	c.columns = append([]encoderColumn(nil), e.columns...)
+	c.row = make([]string, len(e.columns))
+	if e.fieldEncoders != nil {
+		c.fieldEncoders = make(map[string]func(reflect.Value) (string, error), len(e.fieldEncoders))
+		for key, f := range e.fieldEncoders {
+			c.fieldEncoders[key] = f
+		}
+	}
+	c.rows, c.fields = 0, 0
+	c.multi = multiCells{}
+	return &c
+}
+
+// Reset makes the Encoder write to writer, from its first row, e.g. to reuse pooled
+// encoders. Its settings are kept.
+func (e *Encoder) Reset(writer CSVWriter) {
+	e.writer = writer
+	e.headerWritten = false
+	e.rows, e.fields = 0, 0
+}
+
+// Stats returns the number of rows, and of fields in them, written by Encode,
+// EncodeAll and EncodeMap since the Encoder was created or Reset. Header rows are not
+// counted. Use NewCountingCSVWriter for the number of bytes written.
+func (e *Encoder) Stats() (rows int, fields int) {
+	return e.rows, e.fields
+}
+
+func (e *Encoder) maybeWriteHeader() error {
+	if !e.writeHeader || e.headerWritten {
+		return nil
+	}
+	return e.WriteHeader()
+}
+
+// ReverseColumns reverses the order of the columns, for both the header and the rows.
+// Calling it twice restores the struct order.
+func (e *Encoder) ReverseColumns() {
+	for i, j := 0, len(e.columns)-1; i < j; i, j = i+1, j-1 {
+		e.columns[i], e.columns[j] = e.columns[j], e.columns[i]
+	}
+}
+
+// SortColumnsByHeader orders the columns alphabetically by header, for both the header
+// and the rows, so the output keeps its layout however the struct fields move. Columns
+// with the same header keep their relative order. SetView restores the struct order.
+func (e *Encoder) SortColumnsByHeader() {
+	sort.SliceStable(e.columns, func(i, j int) bool {
+		return e.headerKey(i) < e.headerKey(j)
+	})
+}
+
+// SetHeaderKeyIndex sets which of the tag keys of each field WriteHeader writes, from
+// 0, the default, so that `csv:"name,Nom"` writes Nom with 1. The first key still
+// identifies the field, e.g. for EncodeMap and the redactor. A field with fewer keys
+// has an empty header.
+func (e *Encoder) SetHeaderKeyIndex(i int) {
+	e.headerKeyIndex = i
+}
+
+// headerKey returns the key of the column i written by WriteHeader.
+func (e *Encoder) headerKey(i int) string {
+	keys := e.columns[i].fieldInfo.keys
+	if e.headerKeyIndex < 0 || e.headerKeyIndex >= len(keys) {
+		return ""
+	}
+	return keys[e.headerKeyIndex]
+}
+
+// manifestColumn describes a column in the manifest written by WriteManifest.
+type manifestColumn struct {
+	Header      string `json:"header"`
+	Field       string `json:"field"`
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+}
+
+// SetColumnDescriptions sets the descriptions of the columns by header written by
+// WriteManifest, e.g. the map returned by the GenerateHeaderComments function of the
+// codegen package. The headers are normalized with the normalizer set with
+// SetHeaderNormalizer, like the tag keys. The columns missing from descriptions have none.
+func (e *Encoder) SetColumnDescriptions(descriptions map[string]string) {
+	e.descriptions = make(map[string]string, len(descriptions))
+	for header, description := range descriptions {
+		e.descriptions[normalizeName(header)] = description
+	}
+}
+
+// WriteManifest writes to w a JSON array describing the columns, in the order they are
+// written: the header of each, the Go field it is written from, like Address.City or
+// Items[0].Name, the type of that field, e.g. for the consumers of an export, and its
+// description when set with SetColumnDescriptions.
+func (e *Encoder) WriteManifest(w io.Writer) error {
+	manifest := make([]manifestColumn, len(e.columns))
+	for i := range e.columns {
+		var field strings.Builder
+		t := e.inType
+		for _, index := range e.columns[i].fieldInfo.IndexChain {
+			for t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+				fmt.Fprintf(&field, "[%d]", index)
+				t = t.Elem()
+				continue
+			}
+			if field.Len() > 0 {
+				field.WriteByte('.')
+			}
+			field.WriteString(t.Field(index).Name)
+			t = t.Field(index).Type
+		}
+		header := e.headerKey(i)
+		manifest[i] = manifestColumn{Header: header, Field: field.String(), Type: t.String(), Description: e.descriptions[header]}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(manifest)
+}
+
+// WriteHeader writes the CSV header built from the struct tags.
+func (e *Encoder) WriteHeader() error {
+	for i := range e.columns {
+		e.row[i] = e.headerKey(i)
+	}
+	e.headerWritten = true
+	return e.writer.Write(e.row)
+}
+
+// WriteHeaders writes rowCount header rows, the nth one made of the nth tag key of
+// each field, so `csv:"GroupA,Revenue"` gives GroupA in the first row and Revenue in
+// the second. A field with fewer keys repeats its last key.
+func (e *Encoder) WriteHeaders(rowCount int) error {
+	e.headerWritten = true
+	for r := 0; r < rowCount; r++ {
+		for i := range e.columns {
+			keys := e.columns[i].fieldInfo.keys
+			k := r
+			if k >= len(keys) {
+				k = len(keys) - 1
+			}
+			e.row[i] = keys[k]
+		}
+		if err := e.writer.Write(e.row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteHeaderValues writes headers in place of the header built from the struct tags,
+// e.g. localized column names. The rows are still written by field, so headers must
+// have one value per column.
+func (e *Encoder) WriteHeaderValues(headers []string) error {
+	if len(headers) != len(e.columns) {
+		return fmt.Errorf("cannot write %d header values for %d columns", len(headers), len(e.columns))
+	}
+	e.headerWritten = true
+	return e.writer.Write(headers)
+}
+
+// Encode writes in, a struct or a pointer to a struct of the Encoder type, as one CSV row.
+func (e *Encoder) Encode(in interface{}) error {
+	if err := e.maybeWriteHeader(); err != nil {
+		return err
+	}
+	return e.encodeValue(reflect.ValueOf(in))
+}
+
+// EncodeAll writes each element of in, a slice or an array of the Encoder
+// type (or pointers to it), as one CSV row. The header is not written, unless
+// set with SetWriteHeader.
+func (e *Encoder) EncodeAll(in interface{}) error {
+	inValue, inType := getConcreteReflectValueAndType(in) // Get the concrete type (not pointer) (Slice<?> or Array<?>)
+	if err := ensureInType(inType); err != nil {
+		return err
+	}
+	if err := e.maybeWriteHeader(); err != nil {
+		return err
+	}
+	inLen := inValue.Len()
+	for i := 0; i < inLen; i++ { // Iterate over container rows
+		if err := e.encodeValue(inValue.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncodeMap writes row as one CSV row, each value in the column whose header is its
+// key, e.g. for rows built at run time. The columns without a key are left empty, and
+// a key matching no column is an error.
+func (e *Encoder) EncodeMap(row map[string]string) error {
+	for key := range row {
+		if e.columnIndex(key) < 0 {
+			return fmt.Errorf("cannot encode key %s, no column has this header", key)
+		}
+	}
+	if err := e.maybeWriteHeader(); err != nil {
+		return err
+	}
+	for j := range e.row {
+		e.row[j] = ""
+	}
+	for key, value := range row {
+		e.row[e.columnIndex(key)] = value
+	}
+	return e.writeRow()
+}
+
+// columnIndex returns the index of the column whose header is key, or -1.
+func (e *Encoder) columnIndex(key string) int {
+	key = normalizeName(key)
+	for j := range e.columns {
+		if e.columns[j].fieldInfo.getFirstKey() == key {
+			return j
+		}
+	}
+	return -1
+}
+
+// Flush writes any buffered data to the underlying writer and reports any error.
+func (e *Encoder) Flush() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *Encoder) encodeValue(v reflect.Value) error {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return fmt.Errorf("cannot encode nil value")
+	}
+	valueType := v.Type()
+	if v.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+	if valueType != e.inType {
+		return fmt.Errorf("cannot encode %s with an encoder of %s", v.Type(), e.inType)
+	}
+	if e.preWriteHook != nil {
+		if v.Kind() != reflect.Ptr {
+			p := reflect.New(v.Type())
+			p.Elem().Set(v)
+			v = p
+		}
+		skip, err := e.callPreWriteHook(v.Interface())
+		if err != nil {
+			return err
+		}
+		if skip {
+			return nil
+		}
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			for j := range e.row {
+				e.row[j] = ""
+			}
+			return e.writeRow()
+		}
+		v = v.Elem()
+	}
+	e.multi.reset()
+	for j := range e.columns {
+		column := &e.columns[j]
+		e.row[j] = ""
+		var field reflect.Value
+		if column.direct {
+			field = v.FieldByIndex(column.fieldInfo.IndexChain)
+		} else {
+			var ok bool
+			if field, ok = getInnerFieldValue(v, false, column.fieldInfo.IndexChain); !ok {
+				continue
+			}
+		}
+		var err error
+		switch {
+		case column.encode != nil:
+			e.row[j], err = column.callEncode(field)
+		case column.format != nil:
+			e.row[j] = column.format(field)
+		case column.fieldInfo.multiColumn:
+			e.row[j], err = e.multi.get(field, &column.fieldInfo)
+		default:
+			e.row[j], err = getFieldInfoAsString(field, &column.fieldInfo)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return e.writeRow()
+}
+
+func (e *Encoder) writeRow() error {
+	if e.redactor != nil {
+		for j := range e.row {
+			e.row[j] = e.redactor(j, e.columns[j].fieldInfo.getFirstKey(), e.row[j])
+		}
+	}
+	if err := e.writer.Write(e.row); err != nil {
+		return err
+	}
+	e.rows++
+	e.fields += len(e.row)
+	return nil
+}
+
+// countingWriter adds the number of bytes written to out to n.
+type countingWriter struct {
+	out io.Writer
+	n   *int64
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	n, err := w.out.Write(p)
+	*w.n += int64(n)
+	return n, err
+}
+
+// HeterogeneousChannelError is the error returned by MarshalChan when a value is not
+// of the struct type of the first value, which determines the header, or a pointer to it.
+type HeterogeneousChannelError struct {
+	Index    int          // of the value in the channel, from 0
+	Expected reflect.Type // the struct type of the first value
+	Actual   reflect.Type // nil for a nil value
+}
+
+func (e *HeterogeneousChannelError) Error() string {
+	return fmt.Sprintf("cannot write value %d of type %v in a channel of %v", e.Index, e.Actual, e.Expected)
+}
+
 func writeFromChan(writer CSVWriter, c <-chan interface{}, omitHeaders bool) error {
 	// Get the first value. It wil determine the header structure.
 	firstValue, ok := <-c
@@ -26,6 +587,9 @@ func writeFromChan(writer CSVWriter, c <-chan interface{}, omitHeaders bool) err
 	}
 	inInnerWasPointer := inType.Kind() == reflect.Ptr
 	inInnerStructInfo := getStructInfo(inType) // Get the inner struct info to get CSV annotations
+	if inInnerStructInfo.err != nil {
+		return inInnerStructInfo.err
+	}
 	csvHeadersLabels := make([]string, len(inInnerStructInfo.Fields))
 	for i, fieldInfo := range inInnerStructInfo.Fields { // Used to write the header (first line) in CSV
 		csvHeadersLabels[i] = fieldInfo.getFirstKey()
@@ -35,10 +599,12 @@ func writeFromChan(writer CSVWriter, c <-chan interface{}, omitHeaders bool) err
 			return err
 		}
 	}
+	var multi multiCells
 	write := func(val reflect.Value) error {
-		for j, fieldInfo := range inInnerStructInfo.Fields {
+		multi.reset()
+		for j := range inInnerStructInfo.Fields {
 			csvHeadersLabels[j] = ""
-			inInnerFieldValue, err := getInnerField(val, inInnerWasPointer, fieldInfo.IndexChain) // Get the correct field header <-> position
+			inInnerFieldValue, err := getInnerField(val, inInnerWasPointer, &inInnerStructInfo.Fields[j], &multi) // Get the correct field header <-> position
 			if err != nil {
 				return err
 			}
@@ -52,11 +618,15 @@ func writeFromChan(writer CSVWriter, c <-chan interface{}, omitHeaders bool) err
 	if err := write(inValue); err != nil {
 		return err
 	}
-	for v := range c {
-		val, _ := getConcreteReflectValueAndType(v) // Get the concrete type (not pointer) (Slice<?> or Array<?>)
-		if err := ensureStructOrPtr(inType); err != nil {
-			return err
+	for i := 1; ; i++ {
+		v, ok := <-c
+		if !ok {
+			break
 		}
+		if t := reflect.TypeOf(v); t == nil || (t != inType && (t.Kind() != reflect.Ptr || t.Elem() != inType)) {
+			return &HeterogeneousChannelError{Index: i, Expected: inType, Actual: t}
+		}
+		val, _ := getConcreteReflectValueAndType(v) // Get the concrete type (not pointer) (Slice<?> or Array<?>)
 		if err := write(val); err != nil {
 			return err
 		}
@@ -75,6 +645,9 @@ func writeTo(writer CSVWriter, in interface{}, omitHeaders bool) error {
 		return err
 	}
 	inInnerStructInfo := getStructInfo(inInnerType) // Get the inner struct info to get CSV annotations
+	if inInnerStructInfo.err != nil {
+		return inInnerStructInfo.err
+	}
 	csvHeadersLabels := make([]string, len(inInnerStructInfo.Fields))
 	for i, fieldInfo := range inInnerStructInfo.Fields { // Used to write the header (first line) in CSV
 		csvHeadersLabels[i] = fieldInfo.getFirstKey()
@@ -85,10 +658,12 @@ func writeTo(writer CSVWriter, in interface{}, omitHeaders bool) error {
 		}
 	}
 	inLen := inValue.Len()
+	var multi multiCells
 	for i := 0; i < inLen; i++ { // Iterate over container rows
-		for j, fieldInfo := range inInnerStructInfo.Fields {
+		multi.reset()
+		for j := range inInnerStructInfo.Fields {
 			csvHeadersLabels[j] = ""
-			inInnerFieldValue, err := getInnerField(inValue.Index(i), inInnerWasPointer, fieldInfo.IndexChain) // Get the correct field header <-> position
+			inInnerFieldValue, err := getInnerField(inValue.Index(i), inInnerWasPointer, &inInnerStructInfo.Fields[j], &multi) // Get the correct field header <-> position
 			if err != nil {
 				return err
 			}
@@ -102,6 +677,232 @@ func writeTo(writer CSVWriter, in interface{}, omitHeaders bool) error {
 	return writer.Error()
 }
 
+// recordsCSVWriter is a CSVWriter keeping the rows written in memory.
+type recordsCSVWriter struct {
+	records [][]string
+}
+
+func (w *recordsCSVWriter) Write(row []string) error {
+	w.records = append(w.records, append([]string(nil), row...))
+	return nil
+}
+
+func (w *recordsCSVWriter) Flush() {}
+
+func (w *recordsCSVWriter) Error() error {
+	return nil
+}
+
+func writePretty(out io.Writer, in interface{}) error {
+	records := &recordsCSVWriter{}
+	if err := writeTo(records, in, false); err != nil {
+		return err
+	}
+	// Each cell is quoted as in a CSV, by a csv.Writer of the delimiter of the
+	// SafeCSVWriter set with SetCSVWriter, then padded.
+	comma := selfCSVWriter(ioutil.Discard).Comma
+	var cell bytes.Buffer
+	cellWriter := csv.NewWriter(&cell)
+	cellWriter.Comma = comma
+	var widths []int
+	for _, record := range records.records {
+		for j, value := range record {
+			cell.Reset()
+			cellWriter.Write([]string{value})
+			cellWriter.Flush()
+			if err := cellWriter.Error(); err != nil {
+				return err
+			}
+			record[j] = strings.TrimSuffix(cell.String(), "\n")
+			if j == len(widths) {
+				widths = append(widths, 0)
+			}
+			if width := utf8.RuneCountInString(record[j]); width > widths[j] {
+				widths[j] = width
+			}
+		}
+	}
+	var b bytes.Buffer
+	for _, record := range records.records {
+		for j, value := range record {
+			b.WriteString(value)
+			if j < len(record)-1 {
+				b.WriteString(strings.Repeat(" ", widths[j]-utf8.RuneCountInString(value)))
+				b.WriteRune(comma)
+			}
+		}
+		b.WriteByte('\n')
+	}
+	_, err := out.Write(b.Bytes())
+	return err
+}
+
+func writeDropEmptyColumns(writer CSVWriter, in interface{}) error {
+	inValue, inType := getConcreteReflectValueAndType(in) // Get the concrete type (not pointer) (Slice<?> or Array<?>)
+	if err := ensureInType(inType); err != nil {
+		return err
+	}
+	inInnerWasPointer, inInnerType := getConcreteContainerInnerType(inType) // Get the concrete inner type (not pointer) (Container<"?">)
+	if err := ensureInInnerType(inInnerType); err != nil {
+		return err
+	}
+	inInnerStructInfo := getStructInfo(inInnerType) // Get the inner struct info to get CSV annotations
+	if inInnerStructInfo.err != nil {
+		return inInnerStructInfo.err
+	}
+	inLen := inValue.Len()
+	if inLen == 0 {
+		// every column would be dropped: write the header of MarshalCSV instead
+		return writeTo(writer, in, false)
+	}
+
+	// First pass: keep the fields that are not nil in at least one row
+	var kept []*fieldInfo
+	for j := range inInnerStructInfo.Fields {
+		fieldInfo := &inInnerStructInfo.Fields[j]
+		for i := 0; i < inLen; i++ {
+			if field, ok := getInnerFieldValue(inValue.Index(i), inInnerWasPointer, fieldInfo.IndexChain); ok && !isNilValue(field) {
+				kept = append(kept, fieldInfo)
+				break
+			}
+		}
+	}
+
+	// Second pass: write the kept columns
+	row := make([]string, len(kept))
+	for j, fieldInfo := range kept {
+		row[j] = fieldInfo.getFirstKey()
+	}
+	if err := writer.Write(row); err != nil {
+		return err
+	}
+	var multi multiCells
+	for i := 0; i < inLen; i++ {
+		multi.reset()
+		for j, fieldInfo := range kept {
+			value, err := getInnerField(inValue.Index(i), inInnerWasPointer, fieldInfo, &multi)
+			if err != nil {
+				return err
+			}
+			row[j] = value
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// dedupCSVWriter skips the rows after the header whose key, the cells of keyColumns
+// found in the header, has already been written.
+type dedupCSVWriter struct {
+	CSVWriter
+	keyColumns []string
+	indexes    []int // of keyColumns, nil for every column
+	seen       map[string]bool
+	header     bool
+}
+
+func (w *dedupCSVWriter) Write(row []string) error {
+	if !w.header {
+		w.header = true
+		w.seen = make(map[string]bool)
+		for _, key := range w.keyColumns {
+			index := -1
+			for i, header := range row {
+				if header == key {
+					index = i
+					break
+				}
+			}
+			if index < 0 {
+				return fmt.Errorf("cannot dedup on %s, no column has this header", key)
+			}
+			w.indexes = append(w.indexes, index)
+		}
+		return w.CSVWriter.Write(row)
+	}
+	var key strings.Builder
+	if w.indexes == nil {
+		for _, cell := range row {
+			key.WriteString(strconv.Quote(cell))
+		}
+	} else {
+		for _, i := range w.indexes {
+			key.WriteString(strconv.Quote(row[i]))
+		}
+	}
+	if w.seen[key.String()] {
+		return nil
+	}
+	w.seen[key.String()] = true
+	return w.CSVWriter.Write(row)
+}
+
+// isNilValue reports whether v is a nil pointer, interface, slice or map
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return v.IsNil()
+	}
+	return false
+}
+
+// sortedCopy returns a copy of the slice or array in, stably sorted by less
+func sortedCopy(in interface{}, less func(i, j interface{}) bool) (interface{}, error) {
+	inValue, inType := getConcreteReflectValueAndType(in)
+	if err := ensureInType(inType); err != nil {
+		return nil, err
+	}
+	sorted := reflect.MakeSlice(reflect.SliceOf(inType.Elem()), inValue.Len(), inValue.Len())
+	reflect.Copy(sorted, inValue)
+	sort.SliceStable(sorted.Interface(), func(i, j int) bool {
+		return less(sorted.Index(i).Interface(), sorted.Index(j).Interface())
+	})
+	return sorted.Interface(), nil
+}
+
+func writeZip(out io.Writer, in interface{}) (err error) {
+	inValue, inType := getConcreteReflectValueAndType(in)
+	if err := ensureInInnerType(inType); err != nil {
+		return err
+	}
+	archive := zip.NewWriter(out)
+	defer func() {
+		if closeErr := archive.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	for i := 0; i < inType.NumField(); i++ {
+		field := inType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if err := ensureInType(field.Type); err != nil {
+			continue
+		}
+		if _, innerType := getConcreteContainerInnerType(field.Type); innerType.Kind() != reflect.Struct {
+			continue
+		}
+		name := strings.TrimSpace(splitTag(field.Tag.Get(TagName), TagSeparator)[0])
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		entry, err := archive.Create(name + ".csv")
+		if err != nil {
+			return err
+		}
+		if err := writeTo(getCSVWriter(entry), inValue.Field(i).Interface(), false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func ensureStructOrPtr(t reflect.Type) error {
 	switch t.Kind() {
 	case reflect.Struct:
@@ -132,11 +933,26 @@ func ensureInInnerType(outInnerType reflect.Type) error {
 	return fmt.Errorf("cannot use " + outInnerType.String() + ", only struct supported")
 }
 
-func getInnerField(outInner reflect.Value, outInnerWasPointer bool, index []int) (string, error) {
+// getInnerField returns the cell of the field fieldInfo of outInner. The cells of the
+// MultiFieldMarshaller fields are kept in multi for the other columns of the row.
+func getInnerField(outInner reflect.Value, outInnerWasPointer bool, fieldInfo *fieldInfo, multi *multiCells) (string, error) {
+	field, ok := getInnerFieldValue(outInner, outInnerWasPointer, fieldInfo.IndexChain)
+	if !ok {
+		return "", nil
+	}
+	if fieldInfo.multiColumn {
+		return multi.get(field, fieldInfo)
+	}
+	return getFieldInfoAsString(field, fieldInfo)
+}
+
+// getInnerFieldValue follows the index chain, it returns false when a nil
+// pointer or a missing slice element is found along the way.
+func getInnerFieldValue(outInner reflect.Value, outInnerWasPointer bool, index []int) (reflect.Value, bool) {
 	oi := outInner
 	if outInnerWasPointer {
 		if oi.IsNil() {
-			return "", nil
+			return reflect.Value{}, false
 		}
 		oi = outInner.Elem()
 	}
@@ -145,20 +961,54 @@ func getInnerField(outInner reflect.Value, outInnerWasPointer bool, index []int)
 		i := index[0]
 
 		if i >= oi.Len() {
-			return "", nil
+			return reflect.Value{}, false
 		}
 
 		item := oi.Index(i)
 		if len(index) > 1 {
-			return getInnerField(item, false, index[1:])
+			return getInnerFieldValue(item, false, index[1:])
 		}
-		return getFieldAsString(item)
+		return item, true
 	}
 
 	// because pointers can be nil need to recurse one index at a time and perform nil check
 	if len(index) > 1 {
 		nextField := oi.Field(index[0])
-		return getInnerField(nextField, nextField.Kind() == reflect.Ptr, index[1:])
+		return getInnerFieldValue(nextField, nextField.Kind() == reflect.Ptr, index[1:])
+	}
+	return oi.FieldByIndex(index), true
+}
+
+// trailingNewlineTrimmer holds back the line terminator ending each write until the
+// next one, so that the terminator of the last row is never written.
+type trailingNewlineTrimmer struct {
+	out     io.Writer
+	pending []byte
+}
+
+func (w *trailingNewlineTrimmer) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(p) > 0 && p[0] == '\n' && bytes.Equal(w.pending, []byte("\r")) {
+		w.pending = append(w.pending, '\n') // a CRLF split across two writes
+		p = p[1:]
+	}
+	if len(p) == 0 {
+		return n, nil
+	}
+	if len(w.pending) > 0 {
+		if _, err := w.out.Write(w.pending); err != nil {
+			return 0, err
+		}
+		w.pending = w.pending[:0]
+	}
+	for _, terminator := range []string{"\r\n", "\n", "\r"} {
+		if bytes.HasSuffix(p, []byte(terminator)) {
+			p, w.pending = p[:len(p)-len(terminator)], append(w.pending, terminator...)
+			break
+		}
+	}
+	if _, err := w.out.Write(p); err != nil {
+		return 0, err
 	}
-	return getFieldAsString(oi.FieldByIndex(index))
+	return n, nil
 }
@@ -1,13 +1,19 @@
 package gocsv
 
 import (
+	"archive/zip"
 	"bytes"
 	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"math"
+	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -368,6 +374,23 @@ func Test_writeToChan(t *testing.T) {
 	}
 }
 
+func Test_writeToChanHeterogeneous(t *testing.T) {
+	c := make(chan interface{}, 4)
+	c <- Sample{Foo: "a"}
+	c <- &Sample{Foo: "b"}
+	c <- MultiTagSample{Foo: "c"}
+	close(c)
+	b := bytes.Buffer{}
+	err := MarshalChan(c, NewSafeCSVWriter(csv.NewWriter(&b)))
+	var hetErr *HeterogeneousChannelError
+	if !errors.As(err, &hetErr) {
+		t.Fatalf("expected a HeterogeneousChannelError, got %v", err)
+	}
+	if hetErr.Index != 2 || hetErr.Expected != reflect.TypeOf(Sample{}) || hetErr.Actual != reflect.TypeOf(MultiTagSample{}) {
+		t.Errorf("unexpected error %+v", hetErr)
+	}
+}
+
 // TestRenamedTypes tests for marshaling functions on redefined basic types.
 func TestRenamedTypesMarshal(t *testing.T) {
 	samples := []RenamedSample{
@@ -491,3 +514,1253 @@ func Test_writeTo_nested_struct(t *testing.T) {
 	assertLine(t, []string{"one.boolField1", "one.stringField2", "two.boolField1", "two.stringField2", "three.boolField1", "three.stringField2"}, lines[0])
 	assertLine(t, []string{"false", "email_one", "true", "email_two", "false", "email_three"}, lines[1])
 }
+
+func TestEncoder(t *testing.T) {
+	b := bytes.Buffer{}
+	e, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(&b)), Sample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Encode(Sample{Foo: "a", Bar: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.EncodeAll([]*Sample{{Foo: "b", Bar: 2}, nil}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.EncodeAll([]Sample{{Foo: "c", Bar: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Encode(DateTime{}); err == nil {
+		t.Fatal("expected an error encoding a mismatched type")
+	}
+	if err := e.EncodeAll(Sample{}); err == nil {
+		t.Fatal("expected an error encoding a non slice")
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 lines, got %d", len(lines))
+	}
+	assertLine(t, []string{"foo", "BAR", "Baz", "Quux", "Blah", "SPtr", "Omit"}, lines[0])
+	assertLine(t, []string{"a", "1", "", "0", "", "", ""}, lines[1])
+	assertLine(t, []string{"b", "2", "", "0", "", "", ""}, lines[2])
+	assertLine(t, []string{"", "", "", "", "", "", ""}, lines[3])
+	assertLine(t, []string{"c", "3", "", "0", "", "", ""}, lines[4])
+}
+
+type LatLng struct {
+	Lat, Lng float64
+}
+
+func (l LatLng) CSVHeaders() []string {
+	return []string{"lat", "lng"}
+}
+
+func (l LatLng) MarshalCSVMulti() ([]string, error) {
+	return []string{strconv.FormatFloat(l.Lat, 'f', -1, 64), strconv.FormatFloat(l.Lng, 'f', -1, 64)}, nil
+}
+
+func Test_writeTo_multiFieldMarshaller(t *testing.T) {
+	type multiFieldSample struct {
+		Name  string  `csv:"name"`
+		Loc   LatLng  `csv:"loc"`
+		Other *LatLng `csv:"other"`
+	}
+	b := bytes.Buffer{}
+	s := []multiFieldSample{
+		{Name: "a", Loc: LatLng{1.5, -2}, Other: &LatLng{3, 4}},
+		{Name: "b", Loc: LatLng{0, 0.25}},
+	}
+	if err := writeTo(NewSafeCSVWriter(csv.NewWriter(&b)), s, false); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d", len(lines))
+	}
+	assertLine(t, []string{"name", "loc.lat", "loc.lng", "other.lat", "other.lng"}, lines[0])
+	assertLine(t, []string{"a", "1.5", "-2", "3", "4"}, lines[1])
+	assertLine(t, []string{"b", "0", "0.25", "", ""}, lines[2])
+}
+
+func TestMultiFieldMarshallerInExpandedSlice(t *testing.T) {
+	type stop struct {
+		Name string `csv:"name"`
+		Loc  LatLng `csv:"loc"`
+	}
+	type route struct {
+		Stops []stop `csv:"stops" csv[]:"2"`
+	}
+	routes := []route{{Stops: []stop{{"a", LatLng{1, 2}}, {"b", LatLng{3, 4}}}}}
+	out, err := MarshalString(routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "stops[0].name,stops[0].loc.lat,stops[0].loc.lng,stops[1].name,stops[1].loc.lat,stops[1].loc.lng\na,1,2,b,3,4\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	// the multi columns are encode only, and are not decoded
+	var decoded []route
+	if err := UnmarshalString(out, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 1 || len(decoded[0].Stops) != 2 || decoded[0].Stops[1].Name != "b" || decoded[0].Stops[1].Loc != (LatLng{}) {
+		t.Errorf("unexpected routes %+v", decoded)
+	}
+}
+
+var countedMultiCalls int
+
+type countedMulti struct {
+	A, B, C string
+}
+
+func (countedMulti) CSVHeaders() []string {
+	return []string{"a", "b", "c"}
+}
+
+func (m countedMulti) MarshalCSVMulti() ([]string, error) {
+	countedMultiCalls++
+	return []string{m.A, m.B, m.C}, nil
+}
+
+func TestMultiFieldMarshallerCalledOncePerRow(t *testing.T) {
+	type sample struct {
+		First  countedMulti `csv:"first"`
+		Second countedMulti `csv:"second"`
+	}
+	rows := []sample{{First: countedMulti{"1", "2", "3"}}, {Second: countedMulti{"4", "5", "6"}}}
+	countedMultiCalls = 0
+	out, err := MarshalString(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "first.a,first.b,first.c,second.a,second.b,second.c\n1,2,3,,,\n,,,4,5,6\n"; out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+	if countedMultiCalls != 4 {
+		t.Errorf("expected MarshalCSVMulti to be called once per field and row, got %d calls", countedMultiCalls)
+	}
+
+	b := bytes.Buffer{}
+	e, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(&b)), sample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SortColumnsByHeader()
+	countedMultiCalls = 0
+	if err := e.EncodeAll(rows); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "1,2,3,,,\n,,,4,5,6\n" || countedMultiCalls != 4 {
+		t.Errorf("unexpected csv %q after %d calls", b.String(), countedMultiCalls)
+	}
+}
+
+func TestEncoderReverseColumns(t *testing.T) {
+	b := bytes.Buffer{}
+	e, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(&b)), &MultiTagSample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.ReverseColumns()
+	if err := e.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Encode(MultiTagSample{Foo: "a", Bar: 1}); err != nil {
+		t.Fatal(err)
+	}
+	e.ReverseColumns()
+	if err := e.Encode(MultiTagSample{Foo: "b", Bar: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "BAR,Baz\n1,a\nb,2\n" {
+		t.Fatalf("unexpected csv %q", b.String())
+	}
+	// The struct info shared with other encoders is left untouched.
+	if getStructInfo(reflect.TypeOf(MultiTagSample{})).Fields[0].getFirstKey() != "Baz" {
+		t.Fatal("ReverseColumns modified the cached struct info")
+	}
+}
+
+func TestEncoderSortColumnsByHeader(t *testing.T) {
+	type record struct {
+		Name  string `csv:"name"`
+		Age   int    `csv:"age"`
+		Email string `csv:"email"`
+	}
+	b := bytes.Buffer{}
+	e, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(&b)), record{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SortColumnsByHeader()
+	if err := e.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Encode(record{Name: "a", Age: 1, Email: "a@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	e.SetView("")
+	if err := e.Encode(record{Name: "b", Age: 2, Email: "b@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "age,email,name\n1,a@example.com,a\nb,2,b@example.com\n" {
+		t.Fatalf("unexpected csv %q", b.String())
+	}
+}
+
+func TestEncoderStats(t *testing.T) {
+	b := bytes.Buffer{}
+	writer, written := NewCountingCSVWriter(&b)
+	e, err := NewEncoder(writer, &MultiTagSample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SetWriteHeader(true)
+	if err := e.EncodeAll([]MultiTagSample{{Foo: "a", Bar: 1}, {Foo: "b", Bar: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Encode(MultiTagSample{Foo: "c", Bar: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if rows, fields := e.Stats(); rows != 3 || fields != 6 {
+		t.Errorf("expected 3 rows and 6 fields, got %d and %d", rows, fields)
+	}
+	if *written != int64(b.Len()) || b.Len() == 0 {
+		t.Errorf("expected %d bytes written, got %d", b.Len(), *written)
+	}
+
+	e.Reset(writer)
+	if rows, fields := e.Stats(); rows != 0 || fields != 0 {
+		t.Errorf("expected Reset to clear the stats, got %d rows and %d fields", rows, fields)
+	}
+}
+
+func TestEncoderSetHeaderKeyIndex(t *testing.T) {
+	type product struct {
+		Name  string  `csv:"name,Nom"`
+		Price float64 `csv:"price,Prix"`
+		SKU   string  `csv:"sku"`
+	}
+	b := bytes.Buffer{}
+	e, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(&b)), product{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SetHeaderKeyIndex(1)
+	e.SetWriteHeader(true)
+	if err := e.EncodeMap(map[string]string{"name": "pen", "sku": "p1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "Nom,Prix,\npen,,p1\n" {
+		t.Fatalf("unexpected csv %q", b.String())
+	}
+}
+
+func TestMarshalDedup(t *testing.T) {
+	type visit struct {
+		User string `csv:"user"`
+		Page string `csv:"page"`
+	}
+	visits := []visit{{"a", "home"}, {"b", "home"}, {"a", "home"}, {"a", "help"}, {"b", "home"}}
+
+	b := bytes.Buffer{}
+	if err := MarshalDedup(visits, NewSafeCSVWriter(csv.NewWriter(&b)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "user,page\na,home\nb,home\na,help\n" {
+		t.Errorf("unexpected csv %q", b.String())
+	}
+
+	b.Reset()
+	if err := MarshalDedup(visits, NewSafeCSVWriter(csv.NewWriter(&b)), []string{"user"}); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "user,page\na,home\nb,home\n" {
+		t.Errorf("unexpected csv %q", b.String())
+	}
+
+	if err := MarshalDedup(visits, NewSafeCSVWriter(csv.NewWriter(&b)), []string{"missing"}); err == nil {
+		t.Error("expected an error for an unknown key column")
+	}
+}
+
+func TestEncoderSetPreWriteHook(t *testing.T) {
+	type contact struct {
+		Name  string `csv:"name"`
+		Email string `csv:"email"`
+	}
+	b := bytes.Buffer{}
+	e, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(&b)), contact{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SetPreWriteHook(func(v interface{}) (bool, error) {
+		c := v.(*contact)
+		if c.Name == "" {
+			return false, errors.New("missing name")
+		}
+		c.Email = strings.ToLower(c.Email)
+		return c.Email == "", nil
+	})
+	contacts := []contact{{"a", "A@Example.com"}, {"b", ""}, {"c", "c@example.com"}}
+	if err := e.EncodeAll(contacts); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Encode(contact{Email: "d@example.com"}); err == nil || err.Error() != "missing name" {
+		t.Errorf("expected the error of the hook, got %v", err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "a,a@example.com\nc,c@example.com\n" {
+		t.Errorf("unexpected csv %q", b.String())
+	}
+	if contacts[0].Email != "A@Example.com" {
+		t.Error("expected the hook to change a copy of the encoded value")
+	}
+}
+
+func TestEncoderSetTagSeparator(t *testing.T) {
+	type piped struct {
+		Name string  `csv:"name|omitempty"`
+		Rate float64 `csv:"rate|percent"`
+	}
+	b := bytes.Buffer{}
+	e, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(&b)), piped{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetTagSeparator("|"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Encode(piped{Name: "a", Rate: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "name,rate\na,50%\n" {
+		t.Errorf("unexpected csv %q", b.String())
+	}
+
+	type invalid struct {
+		Code string `csv:"code|transform:reverse"`
+	}
+	e, err = NewEncoder(NewSafeCSVWriter(csv.NewWriter(ioutil.Discard)), invalid{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.SetTagSeparator("|"); err == nil {
+		t.Fatal("expected an error for the invalid tags")
+	}
+	if got := e.headerKey(0); got != "code|transform:reverse" {
+		t.Errorf("expected the columns to be left as is, got %q", got)
+	}
+}
+
+func TestMarshalPretty(t *testing.T) {
+	type city struct {
+		Name       string `csv:"name"`
+		Population int    `csv:"population"`
+		Country    string `csv:"country"`
+	}
+	cities := []city{{"Zürich", 421878, "CH"}, {"Paris, France", 2145906, "FR"}, {"Rome", 2749031, "IT"}}
+	b := bytes.Buffer{}
+	if err := MarshalPretty(cities, &b); err != nil {
+		t.Fatal(err)
+	}
+	expected := "" +
+		"name           ,population,country\n" +
+		"Zürich         ,421878    ,CH\n" +
+		"\"Paris, France\",2145906   ,FR\n" +
+		"Rome           ,2749031   ,IT\n"
+	if b.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, b.String())
+	}
+}
+
+func TestEncoderClone(t *testing.T) {
+	b := bytes.Buffer{}
+	writer := NewSafeCSVWriter(csv.NewWriter(&b))
+	e, err := NewEncoder(writer, MultiTagSample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.ReverseColumns()
+	if err := e.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		c := e.Clone()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if err := c.Encode(MultiTagSample{Foo: strconv.Itoa(i), Bar: i}); err != nil {
+					t.Error(err)
+				}
+			}
+			if rows, _ := c.Stats(); rows != 100 {
+				t.Errorf("expected 100 rows for clone %d, got %d", i, rows)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	lines, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 401 {
+		t.Fatalf("expected 401 lines, got %d", len(lines))
+	}
+	assertLine(t, []string{"BAR", "Baz"}, lines[0])
+	for _, line := range lines[1:] {
+		if line[0] != line[1] {
+			t.Fatalf("unexpected line %v, rows of clones were mixed", line)
+		}
+	}
+}
+
+func TestMarshalMaps(t *testing.T) {
+	in := "name,age,city\nann,30,paris\nbob,,rome\n"
+	rows, err := CSVToMaps(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(rows[1], "age")
+	b := bytes.Buffer{}
+	if err := MarshalMaps(rows, []string{"name", "age", "city"}, NewSafeCSVWriter(csv.NewWriter(&b))); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != in {
+		t.Errorf("expected %q, got %q", in, b.String())
+	}
+
+	b.Reset()
+	if err := MarshalMaps(rows, []string{"name"}, NewSafeCSVWriter(csv.NewWriter(&b))); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "name\nann\nbob\n"; b.String() != expected {
+		t.Errorf("expected %q, got %q", expected, b.String())
+	}
+
+	FailIfUnknownMapKeys = true
+	defer func() { FailIfUnknownMapKeys = false }()
+	err = MarshalMaps(rows, []string{"name"}, NewSafeCSVWriter(csv.NewWriter(ioutil.Discard)))
+	if err == nil || !strings.Contains(err.Error(), `["age" "city"]`) {
+		t.Errorf("expected an error on the unknown keys, got %v", err)
+	}
+}
+
+func TestEncoderSetColumnDescriptions(t *testing.T) {
+	SetHeaderNormalizer(strings.ToUpper)
+	defer SetHeaderNormalizer(DefaultNameNormalizer())
+	type order struct {
+		ID     int    `csv:"id"`
+		Status string `csv:"status"`
+	}
+	e, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(ioutil.Discard)), order{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SetColumnDescriptions(map[string]string{"id": "the number of the order"})
+	b := bytes.Buffer{}
+	if err := e.WriteManifest(&b); err != nil {
+		t.Fatal(err)
+	}
+	var manifest []map[string]string
+	if err := json.Unmarshal(b.Bytes(), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest[0]["header"] != "ID" || manifest[0]["description"] != "the number of the order" || manifest[1]["description"] != "" {
+		t.Errorf("unexpected manifest %v", manifest)
+	}
+}
+
+func TestEncoderWriteManifest(t *testing.T) {
+	type address struct {
+		City string `csv:"city"`
+	}
+	type order struct {
+		ID      int       `csv:"id"`
+		Address *address  `csv:"addr"`
+		Placed  time.Time `csv:"placed"`
+	}
+	e, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(ioutil.Discard)), order{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := bytes.Buffer{}
+	if err := e.WriteManifest(&b); err != nil {
+		t.Fatal(err)
+	}
+	var manifest []map[string]string
+	if err := json.Unmarshal(b.Bytes(), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]string{
+		{"header": "id", "field": "ID", "type": "int"},
+		{"header": "addr.city", "field": "Address.City", "type": "string"},
+		{"header": "placed", "field": "Placed", "type": "time.Time"},
+	}
+	if !reflect.DeepEqual(manifest, expected) {
+		t.Errorf("expected %v, got %v", expected, manifest)
+	}
+}
+
+func TestEncoderSetView(t *testing.T) {
+	type person struct {
+		Name  string `csv:"name"`
+		SSN   string `csv:"ssn,views:admin"`
+		Email string `csv:"email,views:admin|support"`
+	}
+	b := bytes.Buffer{}
+	e, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(&b)), person{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := person{Name: "ann", SSN: "123", Email: "ann@example.com"}
+	for _, view := range []string{"", "admin", "support", "public"} {
+		e.SetView(view)
+		if err := e.WriteHeader(); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Encode(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "name,ssn,email\nann,123,ann@example.com\n" +
+		"name,ssn,email\nann,123,ann@example.com\n" +
+		"name,email\nann,ann@example.com\n" +
+		"name\nann\n"
+	if b.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, b.String())
+	}
+}
+
+func TestEncoderSetRedactor(t *testing.T) {
+	b := bytes.Buffer{}
+	e, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(&b)), MultiTagSample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SetRedactor(func(col int, key, value string) string {
+		if key == "Baz" && value != "" {
+			return "***"
+		}
+		return value
+	})
+	if err := e.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.EncodeAll([]*MultiTagSample{{Foo: "secret", Bar: 1}, nil}); err != nil {
+		t.Fatal(err)
+	}
+	e.SetRedactor(nil)
+	if err := e.Encode(MultiTagSample{Foo: "visible", Bar: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "Baz,BAR\n***,1\n,\nvisible,2\n" {
+		t.Fatalf("unexpected csv %q", b.String())
+	}
+}
+
+func TestEncoderSetWriteHeader(t *testing.T) {
+	b := bytes.Buffer{}
+	e, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(&b)), MultiTagSample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SetWriteHeader(true)
+	if err := e.Encode(MultiTagSample{Foo: "a", Bar: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.EncodeAll([]MultiTagSample{{Foo: "b", Bar: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "Baz,BAR\na,1\nb,2\n" {
+		t.Fatalf("unexpected csv %q", b.String())
+	}
+
+	// a pooled encoder writing a fragment
+	fragment := bytes.Buffer{}
+	e.Reset(NewSafeCSVWriter(csv.NewWriter(&fragment)))
+	e.SetWriteHeader(false)
+	if err := e.EncodeAll([]MultiTagSample{{Foo: "c", Bar: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if fragment.String() != "c,3\n" {
+		t.Fatalf("unexpected fragment %q", fragment.String())
+	}
+}
+
+func TestEncoderEncodeMap(t *testing.T) {
+	b := bytes.Buffer{}
+	e, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(&b)), MultiTagSample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SetWriteHeader(true)
+	if err := e.EncodeMap(map[string]string{"BAR": "1", "Baz": "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.EncodeMap(map[string]string{"BAR": "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.EncodeMap(map[string]string{"Foo": "b"}); err == nil {
+		t.Error("expected an error for a key matching no header")
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "Baz,BAR\na,1\n,2\n" {
+		t.Fatalf("unexpected csv %q", b.String())
+	}
+}
+
+func TestEncoderWriteHeaderValues(t *testing.T) {
+	b := bytes.Buffer{}
+	e, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(&b)), MultiTagSample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.WriteHeaderValues([]string{"Nom"}); err == nil {
+		t.Fatal("expected an error for a short header")
+	}
+	if err := e.WriteHeaderValues([]string{"Nom", "Valeur"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Encode(MultiTagSample{Foo: "a", Bar: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "Nom,Valeur\na,1\n" {
+		t.Fatalf("unexpected csv %q", b.String())
+	}
+}
+
+func TestEncoderWriteHeaders(t *testing.T) {
+	type report struct {
+		Name    string  `csv:"Name"`
+		Revenue float64 `csv:"GroupA,Revenue"`
+		Cost    float64 `csv:"GroupA,Cost"`
+	}
+	b := bytes.Buffer{}
+	e, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(&b)), report{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.WriteHeaders(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Encode(report{Name: "a", Revenue: 1, Cost: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "Name,GroupA,GroupA\nName,Revenue,Cost\na,1,2\n" {
+		t.Fatalf("unexpected csv %q", b.String())
+	}
+}
+
+func TestEncoderSetFieldEncoder(t *testing.T) {
+	b := bytes.Buffer{}
+	e, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(&b)), Sample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SetFieldEncoder("Quux", func(v reflect.Value) (string, error) {
+		return strconv.FormatFloat(v.Float(), 'f', 2, 64), nil
+	})
+	e.SetFieldEncoder("Blah", func(v reflect.Value) (string, error) {
+		if v.IsNil() {
+			return "none", nil
+		}
+		return "#" + strconv.Itoa(int(v.Elem().Int())), nil
+	})
+	blah := 3
+	if err := e.EncodeAll([]Sample{{Foo: "a", Frop: 1.5, Blah: &blah}, {Foo: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	e.SetFieldEncoder("Quux", nil)
+	if err := e.Encode(Sample{Foo: "c", Frop: 1.5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "a,0,,1.50,#3,,\nb,0,,0.00,none,,\nc,0,,1.5,none,,\n"
+	if b.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, b.String())
+	}
+
+	e.SetFieldEncoder("foo", func(v reflect.Value) (string, error) {
+		return "", MarshalError{"field encoder error"}
+	})
+	if err := e.Encode(Sample{}); err == nil {
+		t.Fatal("expected the field encoder error")
+	}
+}
+
+func TestEscapedTagSeparator(t *testing.T) {
+	type escapedTagSample struct {
+		Name  string `csv:"'Last, First',name"`
+		Place string `csv:"City\\, State, omitempty"`
+		Owner string `csv:"O'Brien"`
+	}
+	header := []string{"Last, First", "City, State", "O'Brien"}
+	structInfo := getStructInfo(reflect.TypeOf(escapedTagSample{}))
+	for i, fieldInfo := range structInfo.Fields {
+		if fieldInfo.getFirstKey() != header[i] {
+			t.Fatalf("expected key %q, got %q", header[i], fieldInfo.getFirstKey())
+		}
+	}
+	if len(structInfo.Fields[0].keys) != 2 || !structInfo.Fields[1].omitEmpty {
+		t.Fatalf("unexpected tag options %+v", structInfo.Fields)
+	}
+
+	s := []escapedTagSample{{Name: "Doe, John", Place: "Austin, TX", Owner: "x"}}
+	csvContent, err := MarshalString(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "\"Last, First\",\"City, State\",O'Brien\n\"Doe, John\",\"Austin, TX\",x\n"
+	if csvContent != expected {
+		t.Fatalf("expected %q, got %q", expected, csvContent)
+	}
+	var out []escapedTagSample
+	if err := UnmarshalString(csvContent, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, out) {
+		t.Fatalf("expected %v, got %v", s, out)
+	}
+}
+
+func TestEscapedCustomTagSeparator(t *testing.T) {
+	type escapedTagSample struct {
+		Ratio string `csv:"a\\|b|ratio"`
+	}
+	TagSeparator = "|"
+	structInfoCache = sync.Map{}
+	defer func() {
+		TagSeparator = ","
+		structInfoCache = sync.Map{}
+	}()
+
+	keys := getStructInfo(reflect.TypeOf(escapedTagSample{})).Fields[0].keys
+	if !reflect.DeepEqual([]string{"a|b", "ratio"}, keys) {
+		t.Fatalf("unexpected keys %q", keys)
+	}
+}
+
+func TestMarshalZip(t *testing.T) {
+	type export struct {
+		Samples  []Sample          `csv:"samples"`
+		Tags     []*MultiTagSample `csv:"tags,omitempty"`
+		Ignored  []Sample          `csv:"-"`
+		Strings  []string          `csv:"strings"`
+		Name     string            `csv:"name"`
+		unexport []Sample
+	}
+	in := export{
+		Samples: []Sample{{Foo: "a", Bar: 1}},
+		Tags:    []*MultiTagSample{{Foo: "b", Bar: 2}, {Foo: "c", Bar: 3}},
+		Ignored: []Sample{{Foo: "x"}},
+		Strings: []string{"y"},
+	}
+	b := bytes.Buffer{}
+	if err := MarshalZip(&in, &b); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"samples.csv": "foo,BAR,Baz,Quux,Blah,SPtr,Omit\na,1,,0,,,\n",
+		"tags.csv":    "Baz,BAR\nb,2\nc,3\n",
+	}
+	if len(archive.File) != len(expected) {
+		t.Fatalf("expected %d files, got %d", len(expected), len(archive.File))
+	}
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != expected[file.Name] {
+			t.Fatalf("expected %q for %s, got %q", expected[file.Name], file.Name, content)
+		}
+	}
+
+	if err := MarshalZip([]Sample{}, &b); err == nil {
+		t.Fatal("expected an error marshalling a slice")
+	}
+
+	// the archive is closed when an entry fails, with the entries written before it
+	type failing struct {
+		Cell failingCell `csv:"cell"`
+	}
+	type partial struct {
+		Samples []Sample  `csv:"samples"`
+		Failing []failing `csv:"failing"`
+	}
+	b.Reset()
+	if err := MarshalZip(partial{Samples: in.Samples, Failing: []failing{{}}}, &b); err == nil {
+		t.Fatal("expected the error of the failing entry")
+	}
+	if archive, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len())); err != nil || len(archive.File) != 2 {
+		t.Fatalf("expected a closed archive, got %v", err)
+	}
+}
+
+type failingCell string
+
+func (failingCell) MarshalCSV() (string, error) {
+	return "", errors.New("cannot marshal the cell")
+}
+
+// newWideStruct returns a value of a struct type of n fields, cycling through
+// string, int and float64 fields, with a nested struct every 50 fields.
+func newWideStruct(n int) interface{} {
+	type nested struct {
+		X int
+		Y string
+	}
+	nestedType := reflect.TypeOf(nested{})
+	fields := make([]reflect.StructField, n)
+	for i := range fields {
+		var t reflect.Type
+		switch {
+		case i%50 == 49:
+			t = nestedType
+		case i%3 == 0:
+			t = reflect.TypeOf("")
+		case i%3 == 1:
+			t = reflect.TypeOf(0)
+		default:
+			t = reflect.TypeOf(0.0)
+		}
+		fields[i] = reflect.StructField{Name: "F" + strconv.Itoa(i), Type: t}
+	}
+	v := reflect.New(reflect.StructOf(fields)).Elem()
+	for i := 0; i < n; i++ {
+		switch f := v.Field(i); f.Kind() {
+		case reflect.String:
+			f.SetString("value")
+		case reflect.Int:
+			f.SetInt(int64(i))
+		case reflect.Float64:
+			f.SetFloat(float64(i) / 7)
+		}
+	}
+	return v.Interface()
+}
+
+func BenchmarkEncoderWideStruct(b *testing.B) {
+	in := newWideStruct(500)
+	e, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(ioutil.Discard)), in)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := e.Encode(in); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestStructInfoCacheByTagName(t *testing.T) {
+	type taggedSample struct {
+		ID   int    `csv:"id" report:"Identifier"`
+		Name string `csv:"name" report:"Full Name"`
+	}
+	in := []taggedSample{{ID: 1, Name: "a"}}
+	out, err := MarshalString(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "id,name\n1,a\n" {
+		t.Fatalf("unexpected csv %q", out)
+	}
+
+	TagName = "report"
+	defer func() {
+		TagName = "csv"
+	}()
+	out, err = MarshalString(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "Identifier,Full Name\n1,a\n" {
+		t.Fatalf("unexpected csv with the report tag %q", out)
+	}
+	var decoded []taggedSample
+	if err := UnmarshalString(out, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, decoded) {
+		t.Fatalf("expected %v, got %v", in, decoded)
+	}
+}
+
+func TestMarshalSorted(t *testing.T) {
+	in := []Sample{{Foo: "b", Bar: 2}, {Foo: "a", Bar: 3}, {Foo: "c", Bar: 2}}
+	b := bytes.Buffer{}
+	err := MarshalSorted(in, NewSafeCSVWriter(csv.NewWriter(&b)), func(i, j interface{}) bool {
+		return i.(Sample).Bar < j.(Sample).Bar
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "foo,BAR,Baz,Quux,Blah,SPtr,Omit\nb,2,,0,,,\nc,2,,0,,,\na,3,,0,,,\n"
+	if b.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, b.String())
+	}
+	if in[0].Foo != "b" || in[1].Foo != "a" || in[2].Foo != "c" {
+		t.Fatalf("the input was modified: %v", in)
+	}
+
+	b.Reset()
+	arr := [2]*Sample{{Foo: "z"}, {Foo: "y"}}
+	err = MarshalSorted(&arr, NewSafeCSVWriter(csv.NewWriter(&b)), func(i, j interface{}) bool {
+		return i.(*Sample).Foo < j.(*Sample).Foo
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "foo,BAR,Baz,Quux,Blah,SPtr,Omit\ny,") {
+		t.Fatalf("unexpected csv %q", b.String())
+	}
+}
+
+func TestMarshalDropEmptyColumns(t *testing.T) {
+	type inner struct {
+		Deep string `csv:"deep"`
+	}
+	type sparse struct {
+		ID    int     `csv:"id"`
+		Note  *string `csv:"note"`
+		Score *int    `csv:"score"`
+		Inner *inner  `csv:"inner"`
+	}
+	score := 0
+	in := []*sparse{{ID: 1}, {ID: 2, Score: &score}, nil}
+	b := bytes.Buffer{}
+	if err := MarshalDropEmptyColumns(in, NewSafeCSVWriter(csv.NewWriter(&b))); err != nil {
+		t.Fatal(err)
+	}
+	expected := "id,score\n1,\n2,0\n,\n"
+	if b.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, b.String())
+	}
+
+	b.Reset()
+	if err := MarshalDropEmptyColumns([]*sparse{}, NewSafeCSVWriter(csv.NewWriter(&b))); err != nil {
+		t.Fatal(err)
+	}
+	header, err := MarshalString([]*sparse{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != header || header == "\n" {
+		t.Fatalf("expected the header %q for an empty slice, got %q", header, b.String())
+	}
+}
+
+func TestSetTrailingNewline(t *testing.T) {
+	samples := []MultiTagSample{{Foo: "a", Bar: 1}, {Foo: "b\n", Bar: 2}}
+	SetTrailingNewline(false)
+	defer SetTrailingNewline(true)
+
+	out, err := MarshalString(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "Baz,BAR\na,1\n\"b\n\",2" {
+		t.Errorf("unexpected csv %q", out)
+	}
+
+	SetCSVWriter(func(out io.Writer) *SafeCSVWriter {
+		csvout := NewSafeCSVWriter(csv.NewWriter(out))
+		csvout.UseCRLF = true
+		return csvout
+	})
+	defer SetCSVWriter(DefaultCSVWriter)
+	if out, _ = MarshalString(samples); out != "Baz,BAR\r\na,1\r\n\"b\r\n\",2" {
+		t.Errorf("unexpected CRLF csv %q", out)
+	}
+
+	// a terminator split across writes is trimmed as a whole
+	b := bytes.Buffer{}
+	w := &trailingNewlineTrimmer{out: &b}
+	for _, p := range []string{"a,1\r", "\n", "b,2\r", "\n"} {
+		if _, err := w.Write([]byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b.String() != "a,1\r\nb,2" {
+		t.Errorf("unexpected split csv %q", b.String())
+	}
+}
+
+func TestMarshalNilAndZeroPointers(t *testing.T) {
+	type pointers struct {
+		Int    *int     `csv:"int"`
+		Float  *float64 `csv:"float"`
+		Bool   *bool    `csv:"bool"`
+		String *string  `csv:"string"`
+	}
+	i, f, b, s := 0, 0.0, false, ""
+	samples := []pointers{{}, {Int: &i, Float: &f, Bool: &b, String: &s}}
+	// an empty string renders empty whether the pointer is nil or not
+	expected := "int,float,bool,string\n,,,\n0,0,false,\n"
+
+	out, err := MarshalString(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	buf := bytes.Buffer{}
+	e, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(&buf)), pointers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.EncodeAll(samples); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected {
+		t.Errorf("expected %q from the Encoder, got %q", expected, buf.String())
+	}
+}
+
+func TestWriteHeaderFor(t *testing.T) {
+	b := bytes.Buffer{}
+	if err := WriteHeaderFor(&MultiTagSample{}, &b); err != nil {
+		t.Fatal(err)
+	}
+	for _, fragment := range [][]MultiTagSample{{{Foo: "a", Bar: 1}}, {{Foo: "b", Bar: 2}, {Foo: "c", Bar: 3}}} {
+		if err := MarshalWithoutHeaders(fragment, &b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b.String() != "Baz,BAR\na,1\nb,2\nc,3\n" {
+		t.Fatalf("unexpected csv %q", b.String())
+	}
+	if err := WriteHeaderFor(nil, &b); err == nil {
+		t.Error("expected an error for a nil sample")
+	}
+}
+
+// latin1Writer converts UTF-8 to ISO-8859-1, failing on the runes it cannot map, like
+// an encoder of golang.org/x/text/encoding/charmap. Each write must hold whole runes.
+type latin1Writer struct {
+	out    io.Writer
+	closed bool
+}
+
+func (w *latin1Writer) Write(p []byte) (int, error) {
+	encoded := make([]byte, 0, len(p))
+	for _, r := range string(p) {
+		if r > 0xFF {
+			return 0, fmt.Errorf("rune %q not in ISO-8859-1", r)
+		}
+		encoded = append(encoded, byte(r))
+	}
+	if _, err := w.out.Write(encoded); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *latin1Writer) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestMarshalEncoding(t *testing.T) {
+	b := bytes.Buffer{}
+	var latin1 *latin1Writer
+	encode := func(out io.Writer) io.Writer {
+		latin1 = &latin1Writer{out: out}
+		return latin1
+	}
+	if err := MarshalEncoding([]MultiTagSample{{Foo: "café", Bar: 1}}, &b, encode); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "Baz,BAR\ncaf\xe9,1\n" || !latin1.closed {
+		t.Fatalf("unexpected csv %q, closed %v", b.String(), latin1.closed)
+	}
+
+	decode := func(in io.Reader) io.Reader {
+		latin1, _ := ioutil.ReadAll(in)
+		runes := make([]rune, len(latin1))
+		for i, c := range latin1 {
+			runes[i] = rune(c)
+		}
+		return strings.NewReader(string(runes))
+	}
+	var samples []MultiTagSample
+	if err := UnmarshalEncoding(&b, &samples, decode); err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 1 || samples[0].Foo != "café" {
+		t.Errorf("unexpected samples %+v", samples)
+	}
+
+	if err := MarshalEncoding([]MultiTagSample{{Foo: "€"}}, &b, encode); err == nil || !latin1.closed {
+		t.Errorf("expected an error for a rune outside of ISO-8859-1 and a closed writer, got %v", err)
+	}
+}
+
+func TestSetCheckFieldTypes(t *testing.T) {
+	type unsupported struct {
+		Name    string            `csv:"name"`
+		Updates chan int          `csv:"updates"`
+		Labels  map[string]string `csv:"labels"`
+		Nested  struct {
+			Done func() `csv:"done"`
+		} `csv:"nested"`
+		Scores []int `csv:"scores"`
+	}
+	if _, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(ioutil.Discard)), unsupported{}); err != nil {
+		t.Fatalf("unexpected error without checks: %v", err)
+	}
+
+	SetCheckFieldTypes(true)
+	defer SetCheckFieldTypes(false)
+	_, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(ioutil.Discard)), unsupported{})
+	if !errors.Is(err, ErrUnsupportedFieldType) {
+		t.Fatalf("expected ErrUnsupportedFieldType, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "updates (chan int), labels (map[string]string), nested.done (func()), scores ([]int)") {
+		t.Errorf("unexpected error %v", err)
+	}
+	_, err = NewUnmarshaller(csv.NewReader(strings.NewReader("name\n")), unsupported{})
+	if !errors.Is(err, ErrUnsupportedFieldType) || !strings.Contains(err.Error(), "updates (chan int), labels (map[string]string), nested.done (func())") {
+		t.Errorf("unexpected decode error %v", err)
+	}
+	if strings.Contains(err.Error(), "scores") {
+		t.Errorf("a slice is decoded from JSON, got %v", err)
+	}
+
+	if _, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(ioutil.Discard)), Sample{}); err != nil {
+		t.Errorf("unexpected error for supported fields: %v", err)
+	}
+}
+
+func TestMarshalInterfaceTypeMarshallers(t *testing.T) {
+	type row struct {
+		TypeMarshaller `csv:"embedded"`
+		Value          TypeMarshaller `csv:"value"`
+		Any            interface{}    `csv:"any"`
+	}
+	rows := []row{
+		{TypeMarshaller: sampleTypeUnmarshaller{"embedded"}, Value: &samplePtrMarshaller{"v"}, Any: sampleTypeUnmarshaller{"any"}},
+		{Any: samplePtrMarshaller{"copied"}},
+	}
+	out, err := MarshalString(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "embedded,value,any\nembedded,ptr:v,any\n,,ptr:copied\n"; out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	// untagged embedded interfaces are not columns
+	type untagged struct {
+		TypeMarshaller
+		Name string `csv:"name"`
+	}
+	if out, err := MarshalString([]untagged{{sampleTypeUnmarshaller{"x"}, "n"}}); err != nil || out != "name\nn\n" {
+		t.Errorf("unexpected csv %q, %v", out, err)
+	}
+
+	b := bytes.Buffer{}
+	e, err := NewEncoder(NewSafeCSVWriter(csv.NewWriter(&b)), row{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Encode(rows[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "embedded,ptr:v,any\n" {
+		t.Errorf("unexpected csv %q", b.String())
+	}
+}
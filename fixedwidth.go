@@ -0,0 +1,91 @@
+package gocsv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// FixedWidthColumn is the position of a column in the lines of a fixed-width file,
+// from the character Start, counted from 0, to the character End, excluded.
+type FixedWidthColumn struct {
+	Start, End int
+}
+
+// FixedWidthDecoder is a SimpleDecoder reading a fixed-width file, which may be passed
+// to the UnmarshalDecoder* family of functions. The file has no header line: the nth
+// column is decoded in the nth field of the struct, in the order of the struct info, and
+// the spaces padding the cells are trimmed.
+type FixedWidthDecoder struct {
+	SimpleDecoder
+}
+
+// NewFixedWidthDecoder creates a FixedWidthDecoder reading in the columns of the struct
+// type of sample, which must be a struct or a pointer to a struct.
+func NewFixedWidthDecoder(in io.Reader, columns []FixedWidthColumn, sample interface{}) (*FixedWidthDecoder, error) {
+	inType := reflect.TypeOf(sample)
+	if inType == nil {
+		return nil, fmt.Errorf("cannot use nil sample, only struct supported")
+	}
+	if inType.Kind() == reflect.Ptr {
+		inType = inType.Elem()
+	}
+	if err := ensureOutInnerType(inType); err != nil {
+		return nil, err
+	}
+	structInfo := getStructInfo(inType)
+	if structInfo.err != nil {
+		return nil, structInfo.err
+	}
+	fields := structInfo.Fields
+	if len(columns) > len(fields) {
+		return nil, fmt.Errorf("cannot decode %d fixed-width columns in %d fields", len(columns), len(fields))
+	}
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		if column.Start < 0 || column.End < column.Start {
+			return nil, fmt.Errorf("invalid fixed-width column %d: %d to %d", i, column.Start, column.End)
+		}
+		headers[i] = fields[i].getFirstKey()
+	}
+	r := &fixedWidthReader{in: bufio.NewReader(in), columns: columns}
+	return &FixedWidthDecoder{NewSimpleDecoderWithHeaders(headers, r)}, nil
+}
+
+// fixedWidthReader is a CSVReader slicing each line of in into columns. Empty lines
+// are skipped.
+type fixedWidthReader struct {
+	in      *bufio.Reader
+	columns []FixedWidthColumn
+}
+
+func (r *fixedWidthReader) Read() ([]string, error) {
+	for {
+		line, err := r.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			continue
+		}
+		chars := []rune(line)
+		record := make([]string, len(r.columns))
+		for i, column := range r.columns {
+			start, end := column.Start, column.End
+			if end > len(chars) {
+				end = len(chars)
+			}
+			if start < end {
+				record[i] = strings.TrimSpace(string(chars[start:end]))
+			}
+		}
+		return record, nil
+	}
+}
+
+func (r *fixedWidthReader) ReadAll() ([][]string, error) {
+	return readAll(r)
+}
@@ -0,0 +1,45 @@
+package gocsv
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFixedWidthDecoder(t *testing.T) {
+	type account struct {
+		ID      int     `csv:"id"`
+		Name    string  `csv:"name"`
+		Balance float64 `csv:"balance"`
+	}
+	in := "0001Alice       12.50\r\n0002Bob Smith  -3\n\n0003Édouard\n"
+	columns := []FixedWidthColumn{{0, 4}, {4, 15}, {15, 21}}
+
+	d, err := NewFixedWidthDecoder(strings.NewReader(in), columns, &account{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var accounts []account
+	if err := UnmarshalDecoder(d, &accounts); err != nil {
+		t.Fatal(err)
+	}
+	expected := []account{{1, "Alice", 12.5}, {2, "Bob Smith", -3}, {3, "Édouard", 0}}
+	if !reflect.DeepEqual(accounts, expected) {
+		t.Errorf("expected %+v, got %+v", expected, accounts)
+	}
+
+	d, err = NewFixedWidthDecoder(strings.NewReader("000xAlice\n"), columns[:2], account{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := UnmarshalDecoder(d, &accounts); err == nil {
+		t.Error("expected an error for an invalid int")
+	}
+
+	if _, err := NewFixedWidthDecoder(strings.NewReader(in), append(columns, FixedWidthColumn{21, 30}), account{}); err == nil {
+		t.Error("expected an error for more columns than fields")
+	}
+	if _, err := NewFixedWidthDecoder(strings.NewReader(in), []FixedWidthColumn{{4, 2}}, account{}); err == nil {
+		t.Error("expected an error for an invalid column")
+	}
+}
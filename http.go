@@ -0,0 +1,31 @@
+package gocsv
+
+import (
+	"mime"
+	"net/http"
+)
+
+// utf8BOM lets spreadsheet applications detect that the CSV is UTF-8.
+const utf8BOM = "\xEF\xBB\xBF"
+
+var responseBOM = false
+
+// SetResponseBOM sets whether WriteCSVResponse starts the CSV with a UTF-8 byte order
+// mark, which some spreadsheet applications need to display non ASCII text.
+func SetResponseBOM(b bool) {
+	responseBOM = b
+}
+
+// WriteCSVResponse marshals in, like Marshal, as the body of an HTTP response
+// downloaded as filename. The Content-Type and Content-Disposition headers are set,
+// so it must be called before anything else is written to w.
+func WriteCSVResponse(w http.ResponseWriter, filename string, in interface{}) error {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	if responseBOM {
+		if _, err := w.Write([]byte(utf8BOM)); err != nil {
+			return err
+		}
+	}
+	return Marshal(in, w)
+}
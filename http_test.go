@@ -0,0 +1,35 @@
+package gocsv
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteCSVResponse(t *testing.T) {
+	samples := []Sample{{Foo: "a", Bar: 1}}
+	expected := "foo,BAR,Baz,Quux,Blah,SPtr,Omit\na,1,,0,,,\n"
+
+	rec := httptest.NewRecorder()
+	if err := WriteCSVResponse(rec, "report 2020.csv", samples); err != nil {
+		t.Fatal(err)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+	if cd := rec.Header().Get("Content-Disposition"); cd != `attachment; filename="report 2020.csv"` {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+	if rec.Body.String() != expected {
+		t.Errorf("expected %q, got %q", expected, rec.Body.String())
+	}
+
+	SetResponseBOM(true)
+	defer SetResponseBOM(false)
+	rec = httptest.NewRecorder()
+	if err := WriteCSVResponse(rec, "report.csv", samples); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Body.String() != utf8BOM+expected {
+		t.Errorf("expected a BOM, got %q", rec.Body.String())
+	}
+}
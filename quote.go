@@ -0,0 +1,81 @@
+package gocsv
+
+import "io"
+
+// The encoding/csv reader and writer only know the double quote. A CSV quoted with
+// another character is read and written by swapping that character with the double
+// quote, in the input or the output bytes, where the double quote is then an ordinary
+// character and the other one the quote, and again in the cells, to restore them.
+
+// swapQuotes swaps quoteChar and the double quote in p.
+func swapQuotes(p []byte) {
+	q := byte(quoteChar)
+	for i, c := range p {
+		if c == q {
+			p[i] = '"'
+		} else if c == '"' {
+			p[i] = q
+		}
+	}
+}
+
+// swapCellQuotes returns a copy of row with quoteChar and the double quote swapped.
+func swapCellQuotes(row []string) []string {
+	swapped := make([]string, len(row))
+	for i, cell := range row {
+		b := []byte(cell)
+		swapQuotes(b)
+		swapped[i] = string(b)
+	}
+	return swapped
+}
+
+type quoteSwapReader struct {
+	in io.Reader
+}
+
+func (r quoteSwapReader) Read(p []byte) (int, error) {
+	n, err := r.in.Read(p)
+	swapQuotes(p[:n])
+	return n, err
+}
+
+type quoteSwapWriter struct {
+	out io.Writer
+}
+
+func (w quoteSwapWriter) Write(p []byte) (int, error) {
+	swapped := append([]byte(nil), p...)
+	swapQuotes(swapped)
+	return w.out.Write(swapped)
+}
+
+// quoteCharCSVReader restores the cells read from a quoteSwapReader.
+type quoteCharCSVReader struct {
+	CSVReader
+}
+
+func (r quoteCharCSVReader) Read() ([]string, error) {
+	row, err := r.CSVReader.Read()
+	if err != nil {
+		return row, err
+	}
+	return swapCellQuotes(row), nil
+}
+
+func (r quoteCharCSVReader) ReadAll() ([][]string, error) {
+	rows, err := r.CSVReader.ReadAll()
+	for i, row := range rows {
+		rows[i] = swapCellQuotes(row)
+	}
+	return rows, err
+}
+
+// quoteCharCSVWriter swaps the cells written to a quoteSwapWriter.
+type quoteCharCSVWriter struct {
+	CSVWriter
+}
+
+func (w quoteCharCSVWriter) Write(row []string) error {
+	return w.CSVWriter.Write(swapCellQuotes(row))
+}
@@ -0,0 +1,100 @@
+package gocsv
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// QuoteFuncCSVWriter is a thread safe CSVWriter which lets a function decide
+// which cells are quoted. Cells that would not be valid CSV without quotes are
+// always quoted.
+type QuoteFuncCSVWriter struct {
+	Comma   rune // Field delimiter (set to ',' by NewQuoteFuncCSVWriter)
+	UseCRLF bool // True to use \r\n as the line terminator
+
+	shouldQuote func(col int, value string) bool
+	w           *bufio.Writer
+	err         error
+	m           sync.Mutex
+}
+
+// NewQuoteFuncCSVWriter returns a QuoteFuncCSVWriter writing to out, quoting
+// the cells for which shouldQuote returns true.
+func NewQuoteFuncCSVWriter(out io.Writer, shouldQuote func(col int, value string) bool) *QuoteFuncCSVWriter {
+	return &QuoteFuncCSVWriter{
+		Comma:       ',',
+		shouldQuote: shouldQuote,
+		w:           bufio.NewWriter(out),
+	}
+}
+
+// Write writes a single CSV record.
+func (w *QuoteFuncCSVWriter) Write(row []string) error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.write(row)
+	return w.err
+}
+
+func (w *QuoteFuncCSVWriter) write(row []string) error {
+	for n, field := range row {
+		if n > 0 {
+			if _, err := w.w.WriteRune(w.Comma); err != nil {
+				return err
+			}
+		}
+		if !w.fieldNeedsQuotes(field) && (w.shouldQuote == nil || !w.shouldQuote(n, field)) {
+			if _, err := w.w.WriteString(field); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := w.w.WriteString(`"` + strings.Replace(field, `"`, `""`, -1) + `"`); err != nil {
+			return err
+		}
+	}
+	return w.writeTerminator()
+}
+
+func (w *QuoteFuncCSVWriter) writeTerminator() error {
+	if w.UseCRLF {
+		_, err := w.w.WriteString("\r\n")
+		return err
+	}
+	return w.w.WriteByte('\n')
+}
+
+// fieldNeedsQuotes mirrors the rules of encoding/csv.
+func (w *QuoteFuncCSVWriter) fieldNeedsQuotes(field string) bool {
+	if field == "" {
+		return false
+	}
+	if field == `\.` || strings.ContainsRune(field, w.Comma) || strings.ContainsAny(field, "\"\r\n") {
+		return true
+	}
+	r1, _ := utf8.DecodeRuneInString(field)
+	return unicode.IsSpace(r1)
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *QuoteFuncCSVWriter) Flush() {
+	w.m.Lock()
+	defer w.m.Unlock()
+	if err := w.w.Flush(); err != nil && w.err == nil {
+		w.err = err
+	}
+}
+
+// Error reports any error that has occurred during a previous Write or Flush.
+func (w *QuoteFuncCSVWriter) Error() error {
+	w.m.Lock()
+	defer w.m.Unlock()
+	return w.err
+}
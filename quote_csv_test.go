@@ -0,0 +1,43 @@
+package gocsv
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestQuoteFuncCSVWriter(t *testing.T) {
+	b := bytes.Buffer{}
+	w := NewQuoteFuncCSVWriter(&b, func(col int, value string) bool {
+		return col == 0 || strings.Contains(value, "x")
+	})
+	w.Comma = ';'
+	if err := w.Write([]string{"a", "b", "xy", "c;d", `e"f`, ""}); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		t.Fatal(err)
+	}
+	expected := `"a";b;"xy";"c;d";"e""f";` + "\n"
+	if b.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, b.String())
+	}
+}
+
+func TestSetShouldQuote(t *testing.T) {
+	SetShouldQuote(func(col int, value string) bool {
+		return col == 1
+	})
+	defer SetShouldQuote(nil)
+
+	s := []Sample{{Foo: "f", Bar: 1}}
+	csvContent, err := MarshalString(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "foo,\"BAR\",Baz,Quux,Blah,SPtr,Omit\nf,\"1\",,0,,,\n"
+	if csvContent != expected {
+		t.Fatalf("expected %q, got %q", expected, csvContent)
+	}
+}
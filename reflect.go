@@ -13,6 +13,7 @@ import (
 
 type structInfo struct {
 	Fields []fieldInfo
+	err    error // the type nests structs deeper than SetMaxNestingDepth
 }
 
 // fieldInfo is a struct field that should be mapped to a CSV column, or vice-versa
@@ -23,12 +24,49 @@ type fieldInfo struct {
 	omitEmpty    bool
 	IndexChain   []int
 	defaultValue string
+	multiColumn  bool // column multiIndex of a MultiFieldMarshaller, which is encode only
+	multiIndex   int
+	jsonArray    bool   // the column is the JSON array of the slice field
+	json         bool   // the column is the JSON of the field, whatever its type
+	separator    string // the column joins the elements of the slice field with it
+	trueString   string
+	falseString  string
+	presence     bool // the bool field is true when the column is not empty
+	percent      bool // the column is a percentage of the float field
+	iso8601      bool // the column is an ISO 8601 duration of the time.Duration field
+	currency     bool // the column is an amount of the numeric field, like $1,234.56
+	currencySym  string
+	group        bool                // the digits of the numeric field are grouped, like 1,234,567
+	transform    func(string) string // the transform:name tag option, applied to the cell when decoding
+	views        []string
+	indexed      bool // the index:n tag option maps the field to the column n, from 0, whatever its header
+	columnIndex  int
+	decode       func(string) (interface{}, error) // set with SetFieldDecoder, on the copies of an Unmarshaller
 }
 
 func (f fieldInfo) getFirstKey() string {
 	return f.keys[0]
 }
 
+// inView reports whether the field is written in view, which is the case of every
+// field without the views tag option.
+func (f fieldInfo) inView(view string) bool {
+	if view == "" || len(f.views) == 0 {
+		return true
+	}
+	for _, v := range f.views {
+		if v == view {
+			return true
+		}
+	}
+	return false
+}
+
+// hasBoolStrings reports whether the truestr or falsestr tag options are set
+func (f fieldInfo) hasBoolStrings() bool {
+	return f.trueString != "" || f.falseString != ""
+}
+
 func (f fieldInfo) matchesKey(key string) bool {
 	for _, k := range f.keys {
 		if key == k || strings.TrimSpace(key) == k {
@@ -42,20 +80,44 @@ var structInfoCache sync.Map
 var structMap = make(map[reflect.Type]*structInfo)
 var structMapMutex sync.RWMutex
 
+// structInfoKey is the key of structInfoCache: the fields of a type depend on the
+// tag they are read from too.
+type structInfoKey struct {
+	rType        reflect.Type
+	tagName      string
+	tagSeparator string
+	maxDepth     int
+}
+
 func getStructInfo(rType reflect.Type) *structInfo {
-	stInfo, ok := structInfoCache.Load(rType)
+	return getStructInfoWithSeparator(rType, TagSeparator)
+}
+
+// getStructInfoWithSeparator is getStructInfo splitting the tags with tagSeparator in
+// place of TagSeparator, for an Encoder or an Unmarshaller of its own separator.
+func getStructInfoWithSeparator(rType reflect.Type, tagSeparator string) *structInfo {
+	key := structInfoKey{rType, TagName, tagSeparator, maxNestingDepth}
+	stInfo, ok := structInfoCache.Load(key)
 	if ok {
 		return stInfo.(*structInfo)
 	}
 
-	fieldsList := getFieldInfos(rType, []int{}, []string{})
-	stInfo = &structInfo{fieldsList}
-	structInfoCache.Store(rType, stInfo)
+	fieldsList, err := getFieldInfos(rType, []int{}, []string{}, tagSeparator, 1)
+	if err != nil {
+		fieldsList = nil
+	}
+	stInfo = &structInfo{fieldsList, err}
+	structInfoCache.Store(key, stInfo)
 
 	return stInfo.(*structInfo)
 }
 
-func getFieldInfos(rType reflect.Type, parentIndexChain []int, parentKeys []string) []fieldInfo {
+// getFieldInfos returns the fields of rType, a struct nested depth levels deep in the
+// type of the struct info, from 1.
+func getFieldInfos(rType reflect.Type, parentIndexChain []int, parentKeys []string, tagSeparator string, depth int) ([]fieldInfo, error) {
+	if depth > maxNestingDepth {
+		return nil, fmt.Errorf("%w: %s is nested more than %d levels deep", ErrMaxNestingDepthExceeded, rType, maxNestingDepth)
+	}
 	fieldsCount := rType.NumField()
 	fieldsList := make([]fieldInfo, 0, fieldsCount)
 	for i := 0; i < fieldsCount; i++ {
@@ -69,15 +131,60 @@ func getFieldInfos(rType reflect.Type, parentIndexChain []int, parentKeys []stri
 		indexChain := append(cpy, i)
 
 		var currFieldInfo *fieldInfo
-		if !field.Anonymous {
+		// an embedded interface with a csv tag is a column, written through the marshal
+		// methods of its dynamic value; untagged ones are ignored
+		if !field.Anonymous || (field.Type.Kind() == reflect.Interface && field.Tag.Get(TagName) != "") {
 			currFieldInfo = &fieldInfo{IndexChain: indexChain}
 			fieldTag := field.Tag.Get(TagName)
-			fieldTags := strings.Split(fieldTag, TagSeparator)
+			fieldTags := splitTag(fieldTag, tagSeparator)
 			filteredTags := []string{}
 			for _, fieldTagEntry := range fieldTags {
 				trimmedFieldTagEntry := strings.TrimSpace(fieldTagEntry) // handles cases like `csv:"foo, omitempty, default=test"`
 				if trimmedFieldTagEntry == "omitempty" {
 					currFieldInfo.omitEmpty = true
+				} else if trimmedFieldTagEntry == "jsonarray" {
+					currFieldInfo.jsonArray = true
+				} else if trimmedFieldTagEntry == "json" {
+					currFieldInfo.json = true
+				} else if trimmedFieldTagEntry == "presence" {
+					currFieldInfo.presence = true
+				} else if trimmedFieldTagEntry == "percent" {
+					if !isFloatType(field.Type) {
+						return nil, fmt.Errorf("cannot use percent with field %s of type %s, only float supported", field.Name, field.Type)
+					}
+					currFieldInfo.percent = true
+				} else if trimmedFieldTagEntry == "iso8601" {
+					currFieldInfo.iso8601 = true
+				} else if trimmedFieldTagEntry == "group" {
+					currFieldInfo.group = true
+				} else if trimmedFieldTagEntry == "currency" {
+					currFieldInfo.currency = true
+				} else if strings.HasPrefix(trimmedFieldTagEntry, "currency:") {
+					currFieldInfo.currency = true
+					currFieldInfo.currencySym = strings.TrimPrefix(trimmedFieldTagEntry, "currency:")
+				} else if strings.HasPrefix(trimmedFieldTagEntry, "transform:") {
+					name := strings.TrimPrefix(trimmedFieldTagEntry, "transform:")
+					transform, ok := cellTransforms[name]
+					if !ok {
+						return nil, fmt.Errorf("unknown transform %q of field %s", name, field.Name)
+					}
+					currFieldInfo.transform = transform
+				} else if strings.HasPrefix(trimmedFieldTagEntry, "sep:") {
+					currFieldInfo.separator = strings.TrimPrefix(trimmedFieldTagEntry, "sep:")
+				} else if strings.HasPrefix(trimmedFieldTagEntry, "truestr:") {
+					currFieldInfo.trueString = strings.TrimPrefix(trimmedFieldTagEntry, "truestr:")
+				} else if strings.HasPrefix(trimmedFieldTagEntry, "falsestr:") {
+					currFieldInfo.falseString = strings.TrimPrefix(trimmedFieldTagEntry, "falsestr:")
+				} else if strings.HasPrefix(trimmedFieldTagEntry, "index:") {
+					value := strings.TrimPrefix(trimmedFieldTagEntry, "index:")
+					index, err := strconv.Atoi(value)
+					if err != nil || index < 0 {
+						return nil, fmt.Errorf("invalid column index %q in tag of field %s", value, field.Name)
+					}
+					currFieldInfo.indexed = true
+					currFieldInfo.columnIndex = index
+				} else if strings.HasPrefix(trimmedFieldTagEntry, "views:") {
+					currFieldInfo.views = strings.Split(strings.TrimPrefix(trimmedFieldTagEntry, "views:"), "|")
 				} else if strings.HasPrefix(trimmedFieldTagEntry, "default=") {
 					currFieldInfo.defaultValue = strings.TrimPrefix(trimmedFieldTagEntry, "default=")
 				} else {
@@ -107,6 +214,26 @@ func getFieldInfos(rType reflect.Type, parentIndexChain []int, parentKeys []stri
 			}
 		}
 
+		// a MultiFieldMarshaller gets one column per header it declares
+		if currFieldInfo != nil {
+			if headers := multiFieldHeaders(field.Type); headers != nil {
+				for idx, header := range headers {
+					multiFieldInfo := fieldInfo{
+						IndexChain:  indexChain,
+						omitEmpty:   currFieldInfo.omitEmpty,
+						multiColumn: true,
+						multiIndex:  idx,
+						views:       currFieldInfo.views,
+					}
+					for _, key := range currFieldInfo.keys {
+						multiFieldInfo.keys = append(multiFieldInfo.keys, normalizeName(fmt.Sprintf("%s.%s", key, header)))
+					}
+					fieldsList = append(fieldsList, multiFieldInfo)
+				}
+				continue
+			}
+		}
+
 		// handle struct
 		fieldType := field.Type
 		// if the field is a pointer, follow the pointer
@@ -115,15 +242,19 @@ func getFieldInfos(rType reflect.Type, parentIndexChain []int, parentKeys []stri
 		}
 		// if the field is a struct, create a fieldInfo for each of its fields
 		if fieldType.Kind() == reflect.Struct {
-			// unless it implements marshalText or marshalCSV. Structs that implement this
-			// should result in one value and not have their fields exposed
-			if !(canMarshal(fieldType)) {
+			// unless it implements marshalText or marshalCSV, or is tagged with json. Structs
+			// that do should result in one value and not have their fields exposed
+			if !canMarshal(fieldType) && (currFieldInfo == nil || !currFieldInfo.json) {
 				// if the field is an embedded struct, pass along parent keys
 				keys := parentKeys
 				if currFieldInfo != nil {
 					keys = currFieldInfo.keys
 				}
-				fieldsList = append(fieldsList, getFieldInfos(fieldType, indexChain, keys)...)
+				fieldInfos, err := getFieldInfos(fieldType, indexChain, keys, tagSeparator, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				fieldsList = append(fieldsList, fieldInfos...)
 				continue
 			}
 		}
@@ -133,7 +264,10 @@ func getFieldInfos(rType reflect.Type, parentIndexChain []int, parentKeys []stri
 			continue
 		}
 
-		if field.Type.Kind() == reflect.Slice || field.Type.Kind() == reflect.Array {
+		if currFieldInfo.jsonArray || currFieldInfo.json || currFieldInfo.separator != "" {
+			// a single column, whatever the element type
+			fieldsList = append(fieldsList, *currFieldInfo)
+		} else if field.Type.Kind() == reflect.Slice || field.Type.Kind() == reflect.Array {
 			var arrayLength = -1
 			if arrayTag, ok := field.Tag.Lookup(TagName + "[]"); ok {
 				arrayLength, _ = strconv.Atoi(arrayTag)
@@ -141,7 +275,15 @@ func getFieldInfos(rType reflect.Type, parentIndexChain []int, parentKeys []stri
 
 			// When the field is a slice/array of structs, create a fieldInfo for each index and each field
 			if field.Type.Elem().Kind() == reflect.Struct {
-				fieldInfos := getFieldInfos(field.Type.Elem(), []int{}, []string{})
+				// the element struct is only walked when the field is expanded, so that
+				// an unexpanded field like Children []Node is ignored
+				var fieldInfos []fieldInfo
+				if arrayLength > 0 {
+					var err error
+					if fieldInfos, err = getFieldInfos(field.Type.Elem(), []int{}, []string{}, tagSeparator, depth+1); err != nil {
+						return nil, err
+					}
+				}
 
 				for idx := 0; idx < arrayLength; idx++ {
 					// copy index chain and append array index
@@ -153,11 +295,12 @@ func getFieldInfos(rType reflect.Type, parentIndexChain []int, parentKeys []stri
 						var cpy3 = make([]int, len(arrayIndexChain))
 						copy(cpy3, arrayIndexChain)
 
-						arrayFieldInfo := fieldInfo{
-							IndexChain:   append(cpy3, childFieldInfo.IndexChain...),
-							omitEmpty:    childFieldInfo.omitEmpty,
-							defaultValue: childFieldInfo.defaultValue,
-						}
+						// the child options, such as the columns of a MultiFieldMarshaller, are
+						// kept; an index:n option would map every element to one column
+						arrayFieldInfo := childFieldInfo
+						arrayFieldInfo.IndexChain = append(cpy3, childFieldInfo.IndexChain...)
+						arrayFieldInfo.keys = nil
+						arrayFieldInfo.indexed, arrayFieldInfo.columnIndex = false, 0
 
 						// create cartesian product of keys
 						// eg: array field keys x struct field keys
@@ -181,6 +324,16 @@ func getFieldInfos(rType reflect.Type, parentIndexChain []int, parentKeys []stri
 						IndexChain:   append(cpy2, idx),
 						omitEmpty:    currFieldInfo.omitEmpty,
 						defaultValue: currFieldInfo.defaultValue,
+						trueString:   currFieldInfo.trueString,
+						falseString:  currFieldInfo.falseString,
+						presence:     currFieldInfo.presence,
+						percent:      currFieldInfo.percent,
+						iso8601:      currFieldInfo.iso8601,
+						currency:     currFieldInfo.currency,
+						currencySym:  currFieldInfo.currencySym,
+						group:        currFieldInfo.group,
+						transform:    currFieldInfo.transform,
+						views:        currFieldInfo.views,
 					}
 
 					for _, akey := range currFieldInfo.keys {
@@ -196,7 +349,42 @@ func getFieldInfos(rType reflect.Type, parentIndexChain []int, parentKeys []stri
 			fieldsList = append(fieldsList, *currFieldInfo)
 		}
 	}
-	return fieldsList
+	return fieldsList, nil
+}
+
+// splitTag splits a struct tag on sep, like strings.Split, but keeps a separator
+// that is escaped by a backslash (`csv:"Last\\, First"`) or found in an entry
+// enclosed in single quotes (`csv:"'Last, First'"`). A backslash or a single
+// quote can be escaped by a backslash as well.
+func splitTag(tag, sep string) []string {
+	if sep == "" {
+		return strings.Split(tag, sep)
+	}
+	var entries []string
+	var entry strings.Builder
+	quoted := false
+	for i := 0; i < len(tag); {
+		switch {
+		case tag[i] == '\\' && strings.HasPrefix(tag[i+1:], sep):
+			entry.WriteString(sep)
+			i += 1 + len(sep)
+		case tag[i] == '\\' && i+1 < len(tag) && (tag[i+1] == '\\' || tag[i+1] == '\''):
+			entry.WriteByte(tag[i+1])
+			i += 2
+		case tag[i] == '\'' && (quoted || strings.TrimSpace(entry.String()) == ""):
+			// only a leading quote opens a quoted entry, so O'Brien stays as is
+			quoted = !quoted
+			i++
+		case !quoted && strings.HasPrefix(tag[i:], sep):
+			entries = append(entries, entry.String())
+			entry.Reset()
+			i += len(sep)
+		default:
+			entry.WriteByte(tag[i])
+			i++
+		}
+	}
+	return append(entries, entry.String())
 }
 
 func getConcreteContainerInnerType(in reflect.Type) (inInnerWasPointer bool, inInnerType reflect.Type) {
@@ -0,0 +1,24 @@
+//go:build go1.23
+
+package gocsv
+
+import "iter"
+
+// MarshalSeq writes the header built from the struct tags of T, then each value
+// yielded by seq as one CSV row. T must be a struct or a pointer to a struct.
+func MarshalSeq[T any](seq iter.Seq[T], writer CSVWriter) error {
+	var sample T
+	e, err := NewEncoder(writer, sample)
+	if err != nil {
+		return err
+	}
+	if err := e.WriteHeader(); err != nil {
+		return err
+	}
+	for v := range seq {
+		if err := e.Encode(v); err != nil {
+			return err
+		}
+	}
+	return e.Flush()
+}
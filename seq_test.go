@@ -0,0 +1,41 @@
+//go:build go1.23
+
+package gocsv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"slices"
+	"testing"
+)
+
+func TestMarshalSeq(t *testing.T) {
+	b := bytes.Buffer{}
+	samples := []Sample{{Foo: "a", Bar: 1}, {Foo: "b", Bar: 2}}
+	if err := MarshalSeq(slices.Values(samples), NewSafeCSVWriter(csv.NewWriter(&b))); err != nil {
+		t.Fatal(err)
+	}
+	expected := "foo,BAR,Baz,Quux,Blah,SPtr,Omit\na,1,,0,,,\nb,2,,0,,,\n"
+	if b.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, b.String())
+	}
+
+	b.Reset()
+	ptrs := func(yield func(*Sample) bool) {
+		for i := range samples {
+			if !yield(&samples[i]) {
+				return
+			}
+		}
+	}
+	if err := MarshalSeq(ptrs, NewSafeCSVWriter(csv.NewWriter(&b))); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != expected {
+		t.Fatalf("expected %q from pointers, got %q", expected, b.String())
+	}
+
+	if err := MarshalSeq(slices.Values([]int{1}), NewSafeCSVWriter(csv.NewWriter(&b))); err == nil {
+		t.Fatal("expected an error for a non struct type")
+	}
+}
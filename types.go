@@ -1,11 +1,17 @@
 package gocsv
 
 import (
+	"database/sql"
 	"encoding"
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"encoding/json"
 )
@@ -30,6 +36,21 @@ type TypeUnmarshalCSVWithFields interface {
 	UnmarshalCSVWithFields(key, value string) error
 }
 
+// MultiFieldMarshaller is implemented by any value that spans several CSV columns
+// CSVHeaders returns the header suffix of each column, it is called on the zero value of the type
+// MarshalCSVMulti returns one cell per column, in the same order
+type MultiFieldMarshaller interface {
+	CSVHeaders() []string
+	MarshalCSVMulti() ([]string, error)
+}
+
+// TypeEmptyChecker is implemented by any value that has an IsCSVEmpty method
+// It lets a type decide when it is empty: such a value is encoded as an empty cell, and an
+// empty cell leaves the field at its zero value (or nil pointer), which should report itself as empty
+type TypeEmptyChecker interface {
+	IsCSVEmpty() bool
+}
+
 // NoUnmarshalFuncError is the custom error type to be raised in case there is no unmarshal function defined on type
 type NoUnmarshalFuncError struct {
 	msg string
@@ -39,6 +60,27 @@ func (e NoUnmarshalFuncError) Error() string {
 	return e.msg
 }
 
+// ConversionError is the error raised when a cell cannot be converted to its struct field.
+// While unmarshalling, it is the Err of the returned *csv.ParseError, except for the
+// errors of a TypeUnmarshaller or encoding.TextUnmarshaler, which are the Err as they
+// are. The errors of a TypeUnmarshalCSVWithFields are wrapped in it, and other user
+// errors may be wrapped further: match them with errors.As or errors.Is.
+type ConversionError struct {
+	Line   int    // line of the cell in the CSV, 0 if unknown
+	Column string // header of the cell column, or the field key without headers
+	Value  string // content of the cell
+	Err    error  // conversion error
+}
+
+func (e *ConversionError) Error() string {
+	return fmt.Sprintf("cannot convert %q of column %s: %v", e.Value, e.Column, e.Err)
+}
+
+// Unwrap returns the underlying conversion error.
+func (e *ConversionError) Unwrap() error {
+	return e.Err
+}
+
 // NoMarshalFuncError is the custom error type to be raised in case there is no marshal function defined on type
 type NoMarshalFuncError struct {
 	ty reflect.Type
@@ -58,23 +100,58 @@ func toString(in interface{}) (string, error) {
 	case reflect.String:
 		return inValue.String(), nil
 	case reflect.Bool:
-		b := inValue.Bool()
-		if b {
-			return "true", nil
-		}
-		return "false", nil
+		return formatBool(inValue.Bool()), nil
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return fmt.Sprintf("%v", inValue.Int()), nil
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		return fmt.Sprintf("%v", inValue.Uint()), nil
 	case reflect.Float32:
-		return strconv.FormatFloat(inValue.Float(), byte('f'), -1, 32), nil
+		return formatFloat(inValue.Float(), 32), nil
 	case reflect.Float64:
-		return strconv.FormatFloat(inValue.Float(), byte('f'), -1, 64), nil
+		return formatFloat(inValue.Float(), 64), nil
 	}
 	return "", fmt.Errorf("No known conversion from " + inValue.Type().String() + " to string")
 }
 
+// formatFloat formats f as set with SetFloatFormat and SetAccountingNegatives.
+func formatFloat(f float64, bitSize int) string {
+	if accountingNegatives && f < 0 {
+		return "(" + strconv.FormatFloat(-f, floatFormat, floatPrecision, bitSize) + ")"
+	}
+	return strconv.FormatFloat(f, floatFormat, floatPrecision, bitSize)
+}
+
+// parseFlexibleInt parses s, an integer in scientific notation like 1e3, as a float
+// when set with SetFlexibleIntParsing. ok is false when s is to be parsed as usual.
+func parseFlexibleInt(s string) (f float64, ok bool, err error) {
+	if !flexibleIntParsing || !strings.ContainsAny(s, "eE") {
+		return 0, false, nil
+	}
+	if unsigned := strings.TrimLeft(s, "+-"); strings.HasPrefix(unsigned, "0x") || strings.HasPrefix(unsigned, "0X") {
+		return 0, false, nil
+	}
+	f, err = strconv.ParseFloat(s, 64)
+	if err != nil {
+		return f, true, err
+	}
+	if f != math.Trunc(f) {
+		return 0, true, fmt.Errorf("%s is not an integer", s)
+	}
+	return f, true, nil
+}
+
+// fromAccountingNumber converts an accounting number, (123.45) for -123.45 or +5 for
+// 5, to the number parsed by strconv when set with SetAccountingNumberParsing.
+func fromAccountingNumber(s string) string {
+	if !accountingNumberParsing {
+		return s
+	}
+	if len(s) > 2 && s[0] == '(' && s[len(s)-1] == ')' {
+		return "-" + strings.TrimSpace(s[1:len(s)-1])
+	}
+	return strings.TrimPrefix(s, "+")
+}
+
 func toBool(in interface{}) (bool, error) {
 	inValue := reflect.ValueOf(in)
 
@@ -82,6 +159,9 @@ func toBool(in interface{}) (bool, error) {
 	case reflect.String:
 		s := inValue.String()
 		s = strings.TrimSpace(s)
+		if activeBoolLocale != nil && s != "" {
+			return activeBoolLocale.parse(s)
+		}
 		if strings.EqualFold(s, "yes") {
 			return true, nil
 		} else if strings.EqualFold(s, "no") || s == "" {
@@ -113,15 +193,54 @@ func toBool(in interface{}) (bool, error) {
 	return false, fmt.Errorf("No known conversion from " + inValue.Type().String() + " to bool")
 }
 
+// formatBool formats b with the first words of the locale set with SetBoolLocale, if any.
+func formatBool(b bool) string {
+	if locale := activeBoolLocale; locale != nil {
+		if b && len(locale.trueWords) > 0 {
+			return locale.trueWords[0]
+		} else if !b && len(locale.falseWords) > 0 {
+			return locale.falseWords[0]
+		}
+	}
+	return strconv.FormatBool(b)
+}
+
+func (l *boolLocale) parse(s string) (bool, error) {
+	for _, word := range l.trueWords {
+		if strings.EqualFold(s, word) {
+			return true, nil
+		}
+	}
+	for _, word := range l.falseWords {
+		if strings.EqualFold(s, word) {
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("cannot parse %q as a bool of the locale %s, expected one of %q or %q", s, l.name, l.trueWords, l.falseWords)
+}
+
 func toInt(in interface{}) (int64, error) {
 	inValue := reflect.ValueOf(in)
 
 	switch inValue.Kind() {
 	case reflect.String:
-		s := strings.TrimSpace(inValue.String())
+		s := fromAccountingNumber(strings.TrimSpace(inValue.String()))
 		if s == "" {
 			return 0, nil
 		}
+		if f, ok, err := parseFlexibleInt(s); ok {
+			if err == nil && (f < -(1<<63) || f >= 1<<63) {
+				err = &strconv.NumError{Func: "ParseInt", Num: s, Err: strconv.ErrRange}
+			}
+			if isRangeError(err) {
+				// like strconv.ParseInt, so that the value is clamped to the right end
+				if f < 0 {
+					return math.MinInt64, err
+				}
+				return math.MaxInt64, err
+			}
+			return int64(f), err
+		}
 		out := strings.SplitN(s, ".", 2)
 		return strconv.ParseInt(out[0], 0, 64)
 	case reflect.Bool:
@@ -144,10 +263,23 @@ func toUint(in interface{}) (uint64, error) {
 
 	switch inValue.Kind() {
 	case reflect.String:
-		s := strings.TrimSpace(inValue.String())
+		s := fromAccountingNumber(strings.TrimSpace(inValue.String()))
 		if s == "" {
 			return 0, nil
 		}
+		if f, ok, err := parseFlexibleInt(s); ok {
+			if f < 0 {
+				// like strconv.ParseUint, the negative integers are told apart by setUintField
+				return 0, &strconv.NumError{Func: "ParseUint", Num: s, Err: strconv.ErrSyntax}
+			}
+			if err == nil && f >= 1<<64 {
+				err = &strconv.NumError{Func: "ParseUint", Num: s, Err: strconv.ErrRange}
+			}
+			if isRangeError(err) {
+				return math.MaxUint64, err
+			}
+			return uint64(f), err
+		}
 
 		// support the float input
 		if strings.Contains(s, ".") {
@@ -178,7 +310,7 @@ func toFloat(in interface{}) (float64, error) {
 
 	switch inValue.Kind() {
 	case reflect.String:
-		s := strings.TrimSpace(inValue.String())
+		s := fromAccountingNumber(strings.TrimSpace(inValue.String()))
 		if s == "" {
 			return 0, nil
 		}
@@ -200,6 +332,18 @@ func toFloat(in interface{}) (float64, error) {
 }
 
 func setField(field reflect.Value, value string, omitEmpty bool) error {
+	if value == "" && implementsEmptyChecker(field.Type()) {
+		return nil
+	}
+	if field.Type() == errorInterface {
+		// the message of an error field, as encoded by getFieldAsString
+		if value == "" {
+			field.Set(reflect.Zero(field.Type()))
+		} else {
+			field.Set(reflect.ValueOf(errors.New(value)))
+		}
+		return nil
+	}
 	if field.Kind() == reflect.Ptr {
 		if omitEmpty && value == "" {
 			return nil
@@ -224,23 +368,30 @@ func setField(field reflect.Value, value string, omitEmpty bool) error {
 		}
 		field.SetBool(b)
 	case int, int8, int16, int32, int64:
-		i, err := toInt(value)
-		if err != nil {
-			return err
-		}
-		field.SetInt(i)
+		return setIntField(field, value)
 	case uint, uint8, uint16, uint32, uint64:
-		ui, err := toUint(value)
-		if err != nil {
-			return err
-		}
-		field.SetUint(ui)
+		return setUintField(field, value)
 	case float32, float64:
 		f, err := toFloat(value)
 		if err != nil {
 			return err
 		}
 		field.SetFloat(f)
+	case time.Time:
+		t, err := toTime(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+	case json.RawMessage:
+		// the cell is kept as is, without checking that it is JSON
+		if value == "" {
+			field.Set(reflect.Zero(field.Type()))
+		} else {
+			field.SetBytes([]byte(value))
+		}
+	case sql.NullString, sql.NullInt64, sql.NullFloat64, sql.NullBool, sql.NullTime:
+		return setSQLNullField(field, value)
 	default:
 		// Not a native type, check for unmarshal method
 		if err := unmarshall(field, value); err != nil {
@@ -262,17 +413,9 @@ func setField(field reflect.Value, value string, omitEmpty bool) error {
 				}
 				field.SetBool(b)
 			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				i, err := toInt(value)
-				if err != nil {
-					return err
-				}
-				field.SetInt(i)
+				return setIntField(field, value)
 			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-				ui, err := toUint(value)
-				if err != nil {
-					return err
-				}
-				field.SetUint(ui)
+				return setUintField(field, value)
 			case reflect.Float32, reflect.Float64:
 				f, err := toFloat(value)
 				if err != nil {
@@ -294,24 +437,314 @@ func setField(field reflect.Value, value string, omitEmpty bool) error {
 	return nil
 }
 
+// --------------------------------------------------------------------------
+// Integer overflow, handled as set with SetIntOverflowBehavior
+
+func setIntField(field reflect.Value, value string) error {
+	i, err := toInt(value)
+	if err != nil && !isRangeError(err) {
+		return err
+	}
+	if err == nil && !field.OverflowInt(i) {
+		field.SetInt(i)
+		return nil
+	}
+	switch intOverflowBehavior {
+	case IntOverflowClamp:
+		bits := uint(field.Type().Bits())
+		max := int64(1)<<(bits-1) - 1
+		min := -max - 1
+		if i > max {
+			i = max
+		} else if i < min {
+			i = min
+		}
+		field.SetInt(i)
+		return ErrIntOverflowClamped
+	case IntOverflowZero:
+		field.SetInt(0)
+		return nil
+	}
+	return fmt.Errorf("%w: %s does not fit in %s", ErrIntOverflow, value, field.Type())
+}
+
+func setUintField(field reflect.Value, value string) error {
+	ui, err := toUint(value)
+	negative := false
+	if err != nil && !isRangeError(err) {
+		// a negative integer is below the range of an unsigned one
+		if !isNegativeInt(value) {
+			return err
+		}
+		negative = true
+	}
+	if err == nil && !field.OverflowUint(ui) {
+		field.SetUint(ui)
+		return nil
+	}
+	switch intOverflowBehavior {
+	case IntOverflowClamp:
+		if negative {
+			ui = 0
+		} else {
+			ui = uint64(1)<<uint(field.Type().Bits()) - 1
+		}
+		field.SetUint(ui)
+		return ErrIntOverflowClamped
+	case IntOverflowZero:
+		field.SetUint(0)
+		return nil
+	}
+	return fmt.Errorf("%w: %s does not fit in %s", ErrIntOverflow, value, field.Type())
+}
+
+// isNegativeInt reports whether value is a negative integer, parsed strictly unlike
+// toInt, which drops the decimals.
+func isNegativeInt(value string) bool {
+	s := fromAccountingNumber(strings.TrimSpace(value))
+	if f, ok, err := parseFlexibleInt(s); ok {
+		return f < 0 && (err == nil || isRangeError(err))
+	}
+	i, err := strconv.ParseInt(s, 0, 64)
+	return (err == nil && i < 0) || (isRangeError(err) && strings.HasPrefix(s, "-"))
+}
+
+func isRangeError(err error) bool {
+	var numErr *strconv.NumError
+	return errors.As(err, &numErr) && numErr.Err == strconv.ErrRange
+}
+
+// isClampWarning reports whether err only tells that an integer was clamped, after
+// passing it to the handler set with SetIntOverflowWarningHandler.
+func isClampWarning(err *ConversionError) bool {
+	if !errors.Is(err.Err, ErrIntOverflowClamped) {
+		return false
+	}
+	if intOverflowWarningHandler != nil {
+		intOverflowWarningMutex.Lock()
+		defer intOverflowWarningMutex.Unlock()
+		intOverflowWarningHandler(err)
+	}
+	return true
+}
+
+// setFieldInfo sets the field from the value of the column described by fieldInfo
+func setFieldInfo(field reflect.Value, value string, fieldInfo *fieldInfo) error {
+	if err := checkFieldLength(value); err != nil {
+		return err
+	}
+	if isEmptyCell(value) {
+		value = ""
+	}
+	if fieldInfo.transform != nil {
+		value = fieldInfo.transform(value)
+	}
+	if fieldInfo.decode != nil {
+		return setDecodedField(field, value, fieldInfo.decode)
+	}
+	if fieldInfo.jsonArray {
+		return setJSONArrayField(field, value)
+	}
+	if fieldInfo.json {
+		return setJSONField(field, value)
+	}
+	if fieldInfo.separator != "" {
+		return setSeparatedField(field, value, fieldInfo.separator)
+	}
+	if fieldInfo.percent {
+		number, err := fromPercent(value)
+		if err != nil {
+			return err
+		}
+		value = number
+	}
+	if fieldInfo.iso8601 {
+		nanoseconds, err := fromISO8601Duration(value)
+		if err != nil {
+			return err
+		}
+		value = nanoseconds
+	}
+	if fieldInfo.currency && isNumericType(field.Type()) {
+		value = fromCurrency(value)
+	}
+	if fieldInfo.group && isNumericType(field.Type()) {
+		value = ungroupDigits(strings.TrimSpace(value))
+	}
+	if fieldInfo.presence && isBoolType(field.Type()) {
+		value = strconv.FormatBool(strings.TrimSpace(value) != "")
+	}
+	if fieldInfo.hasBoolStrings() && value != "" && (value == fieldInfo.trueString || value == fieldInfo.falseString) {
+		if field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				field.Set(reflect.New(field.Type().Elem()))
+			}
+			field = field.Elem()
+		}
+		if field.Kind() == reflect.Bool {
+			field.SetBool(value == fieldInfo.trueString)
+			return nil
+		}
+	}
+	return setField(field, value, fieldInfo.omitEmpty)
+}
+
+// callFieldDecoder calls decode, recovering from its panics when set with
+// SetRecoverFromPanics.
+func callFieldDecoder(decode func(string) (interface{}, error), value string) (decoded interface{}, err error) {
+	if recoverFromPanics {
+		defer recoverCallbackPanic("SetFieldDecoder", &err)
+	}
+	return decode(value)
+}
+
+// setDecodedField sets field to the value decode returns for the cell value.
+func setDecodedField(field reflect.Value, value string, decode func(string) (interface{}, error)) error {
+	decoded, err := callFieldDecoder(decode, value)
+	if err != nil {
+		return err
+	}
+	if decoded == nil {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	v := reflect.ValueOf(decoded)
+	if !v.Type().AssignableTo(field.Type()) && field.Kind() == reflect.Ptr && v.Type().AssignableTo(field.Type().Elem()) {
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+	}
+	switch {
+	case v.Type().AssignableTo(field.Type()):
+		field.Set(v)
+	case v.Kind() == field.Kind() && v.Type().ConvertibleTo(field.Type()):
+		field.Set(v.Convert(field.Type()))
+	default:
+		return fmt.Errorf("cannot assign %s to a field of type %s", v.Type(), field.Type())
+	}
+	return nil
+}
+
+// checkFieldLength returns ErrMaxFieldLengthExceeded when value is longer than set
+// with SetMaxFieldLength
+func checkFieldLength(value string) error {
+	if maxFieldLength > 0 && len(value) > maxFieldLength {
+		return ErrMaxFieldLengthExceeded
+	}
+	return nil
+}
+
+// isEmptyCell reports whether value is empty, or one of the values set with
+// SetEmptyValues.
+func isEmptyCell(value string) bool {
+	if value == "" {
+		return true
+	}
+	for _, empty := range emptyValues {
+		if value == empty || emptyValuesIgnoreCase && strings.EqualFold(value, empty) {
+			return true
+		}
+	}
+	return false
+}
+
+// getFieldInfoAsString converts the field to the string of the column described by fieldInfo
+func getFieldInfoAsString(field reflect.Value, fieldInfo *fieldInfo) (string, error) {
+	if fieldInfo.multiColumn {
+		return marshallMulti(field, fieldInfo.multiIndex)
+	}
+	if fieldInfo.jsonArray {
+		return getJSONArrayFieldAsString(field)
+	}
+	if fieldInfo.json {
+		return getJSONFieldAsString(field)
+	}
+	if fieldInfo.separator != "" {
+		return getSeparatedFieldAsString(field, fieldInfo.separator)
+	}
+	if fieldInfo.percent {
+		return getPercentFieldAsString(field)
+	}
+	if fieldInfo.iso8601 {
+		return getISO8601FieldAsString(field)
+	}
+	if (fieldInfo.currencySym != "" || fieldInfo.group) && isNumericType(field.Type()) {
+		s, err := getFieldAsString(field)
+		if err != nil || s == "" {
+			return s, err
+		}
+		if fieldInfo.group {
+			s = groupDigits(s)
+		}
+		if fieldInfo.currencySym != "" {
+			s = toCurrency(s, fieldInfo.currencySym)
+		}
+		return s, nil
+	}
+	if fieldInfo.presence && isBoolType(field.Type()) {
+		b := field
+		for b.Kind() == reflect.Ptr {
+			if b.IsNil() {
+				return "", nil
+			}
+			b = b.Elem()
+		}
+		if !b.Bool() {
+			return "", nil
+		}
+		if fieldInfo.trueString != "" {
+			return fieldInfo.trueString, nil
+		}
+		return presenceMarker, nil
+	}
+	if fieldInfo.hasBoolStrings() {
+		b := field
+		if b.Kind() == reflect.Ptr && !b.IsNil() {
+			b = b.Elem()
+		}
+		if b.Kind() == reflect.Bool {
+			if b.Bool() && fieldInfo.trueString != "" {
+				return fieldInfo.trueString, nil
+			} else if !b.Bool() && fieldInfo.falseString != "" {
+				return fieldInfo.falseString, nil
+			}
+		}
+	}
+	return getFieldAsString(field)
+}
+
 func getFieldAsString(field reflect.Value) (str string, err error) {
 	switch field.Kind() {
-	case reflect.Interface, reflect.Ptr:
+	case reflect.Interface:
+		if field.IsNil() {
+			return "", nil
+		}
+		if isErrorType(field.Type()) {
+			return field.Interface().(error).Error(), nil
+		}
+		elem := field.Elem()
+		if elem.Kind() != reflect.Ptr && elem.Kind() != reflect.Interface {
+			// The dynamic value of an interface is not addressable, copy it so
+			// that marshal methods declared on the pointer receiver are found too.
+			addressable := reflect.New(elem.Type()).Elem()
+			addressable.Set(elem)
+			elem = addressable
+		}
+		return getFieldAsString(elem)
+	case reflect.Ptr:
 		if field.IsNil() {
 			return "", nil
 		}
 		return getFieldAsString(field.Elem())
 	default:
+		if isCSVEmpty(field) {
+			return "", nil
+		}
 		// Check if field is go native type
 		switch field.Interface().(type) {
 		case string:
 			return field.String(), nil
 		case bool:
-			if field.Bool() {
-				return "true", nil
-			} else {
-				return "false", nil
-			}
+			return formatBool(field.Bool()), nil
 		case int, int8, int16, int32, int64:
 			return fmt.Sprintf("%v", field.Int()), nil
 		case uint, uint8, uint16, uint32, uint64:
@@ -326,6 +759,12 @@ func getFieldAsString(field reflect.Value) (str string, err error) {
 			if err != nil {
 				return str, err
 			}
+		case time.Time:
+			return timeToString(field.Interface().(time.Time))
+		case json.RawMessage:
+			return string(field.Bytes()), nil
+		case sql.NullString, sql.NullInt64, sql.NullFloat64, sql.NullBool, sql.NullTime:
+			return getSQLNullFieldAsString(field)
 		default:
 			// Not a native type, check for marshal method
 			str, err = marshall(field)
@@ -371,6 +810,62 @@ func getFieldAsString(field reflect.Value) (str string, err error) {
 	return str, nil
 }
 
+// --------------------------------------------------------------------------
+// time.Time: RFC 3339, converted to the location set with SetTimeLocation
+
+// localTimeLayout is RFC 3339 without the zone, accepted when a time location is set
+const localTimeLayout = "2006-01-02T15:04:05"
+
+func toTime(value string) (time.Time, error) {
+	if zeroTimeAsEmpty && strings.TrimSpace(value) == "" {
+		return time.Time{}, nil
+	}
+	if len(timeLayouts) > 0 {
+		return toTimeWithLayouts(value)
+	}
+	var t time.Time
+	if err := t.UnmarshalText([]byte(value)); err != nil {
+		if timeLocation == nil {
+			return t, err
+		}
+		local, localErr := time.ParseInLocation(localTimeLayout, value, timeLocation)
+		if localErr != nil {
+			return t, err
+		}
+		return local, nil
+	}
+	if timeLocation != nil {
+		t = t.In(timeLocation)
+	}
+	return t, nil
+}
+
+// toTimeWithLayouts parses value with the first of the layouts set with SetTimeLayouts
+// that matches it, in the location set with SetTimeLocation or else UTC.
+func toTimeWithLayouts(value string) (time.Time, error) {
+	loc := timeLocation
+	if loc == nil {
+		loc = time.UTC
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t.In(loc), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as a time with any of the layouts %q", value, timeLayouts)
+}
+
+func timeToString(t time.Time) (string, error) {
+	if zeroTimeAsEmpty && t.IsZero() {
+		return "", nil
+	}
+	if timeLocation != nil {
+		t = t.In(timeLocation)
+	}
+	text, err := t.MarshalText()
+	return string(text), err
+}
+
 // --------------------------------------------------------------------------
 // Un/serializations helpers
 
@@ -379,30 +874,769 @@ func canMarshal(t reflect.Type) bool {
 	// should result in one value and not have their fields exposed
 	_, canMarshalText := t.MethodByName("MarshalText")
 	_, canMarshalCSV := t.MethodByName("MarshalCSV")
-	return canMarshalCSV || canMarshalText
+	return canMarshalCSV || canMarshalText || isSQLNullType(t)
+}
+
+// unsupportedFields returns an ErrUnsupportedFieldType naming the fields of structInfo,
+// the one of inType, without a conversion to a cell, with encode, or from a cell. It
+// returns nil when SetCheckFieldTypes is off.
+func unsupportedFields(inType reflect.Type, structInfo *structInfo, encode bool) error {
+	if !checkFieldTypes {
+		return nil
+	}
+	var unsupported []string
+	for _, fieldInfo := range structInfo.Fields {
+		if fieldInfo.multiColumn || fieldInfo.jsonArray || fieldInfo.json {
+			continue
+		}
+		t := inType
+		for _, i := range fieldInfo.IndexChain {
+			for t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			if t.Kind() == reflect.Struct {
+				t = t.Field(i).Type
+			} else {
+				t = t.Elem() // i is the index of a slice or array element
+			}
+		}
+		if !hasConversion(t, encode) {
+			unsupported = append(unsupported, fmt.Sprintf("%s (%s)", fieldInfo.getFirstKey(), t))
+		}
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnsupportedFieldType, strings.Join(unsupported, ", "))
+	}
+	return nil
+}
+
+var marshalerTypes = []reflect.Type{
+	reflect.TypeOf((*TypeMarshaller)(nil)).Elem(),
+	reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem(),
+	reflect.TypeOf((*fmt.Stringer)(nil)).Elem(),
+}
+
+var unmarshalerTypes = []reflect.Type{
+	reflect.TypeOf((*TypeUnmarshaller)(nil)).Elem(),
+	reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem(),
+}
+
+// hasConversion reports whether getFieldAsString, with encode, or setField convert a
+// field of type t.
+func hasConversion(t reflect.Type, encode bool) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == errorInterface || isSQLNullType(t) || t == reflect.TypeOf(time.Time{}) || t == reflect.TypeOf(json.RawMessage(nil)) {
+		return true
+	}
+	if encode {
+		if t.Kind() == reflect.Interface {
+			return true
+		}
+		for _, m := range marshalerTypes {
+			if reflect.PtrTo(t).Implements(m) {
+				return true
+			}
+		}
+	} else {
+		for _, m := range unmarshalerTypes {
+			if reflect.PtrTo(t).Implements(m) {
+				return true
+			}
+		}
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Struct {
+			return true // decoded from JSON
+		}
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// --------------------------------------------------------------------------
+// database/sql Null types: an invalid value is an empty cell, a valid one its inner value
+
+func isSQLNullType(t reflect.Type) bool {
+	switch reflect.Zero(t).Interface().(type) {
+	case sql.NullString, sql.NullInt64, sql.NullFloat64, sql.NullBool, sql.NullTime:
+		return true
+	}
+	return false
+}
+
+func getSQLNullFieldAsString(field reflect.Value) (string, error) {
+	switch v := field.Interface().(type) {
+	case sql.NullString:
+		if v.Valid {
+			return v.String, nil
+		}
+	case sql.NullInt64:
+		if v.Valid {
+			return toString(v.Int64)
+		}
+	case sql.NullFloat64:
+		if v.Valid {
+			return toString(v.Float64)
+		}
+	case sql.NullBool:
+		if v.Valid {
+			return toString(v.Bool)
+		}
+	case sql.NullTime:
+		if v.Valid {
+			return timeToString(v.Time)
+		}
+	}
+	return "", nil
+}
+
+func setSQLNullField(field reflect.Value, value string) error {
+	if value == "" {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	switch field.Interface().(type) {
+	case sql.NullString:
+		field.Set(reflect.ValueOf(sql.NullString{String: value, Valid: true}))
+	case sql.NullInt64:
+		i, err := toInt(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(sql.NullInt64{Int64: i, Valid: true}))
+	case sql.NullFloat64:
+		f, err := toFloat(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(sql.NullFloat64{Float64: f, Valid: true}))
+	case sql.NullBool:
+		b, err := toBool(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(sql.NullBool{Bool: b, Valid: true}))
+	case sql.NullTime:
+		t, err := toTime(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(sql.NullTime{Time: t, Valid: true}))
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+// transforms: the transform:name tag option rewrites the cell before it is decoded
+
+// cellTransforms are the transforms of the transform:name tag option, by name.
+// title upper-cases the first letter of each word separated by white space,
+// leaving the other letters as they are.
+var cellTransforms = map[string]func(string) string{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+	"title": toTitle,
+}
+
+// toTitle upper-cases the first letter of each word of s separated by white space.
+func toTitle(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	wordStart := true
+	for _, r := range s {
+		if wordStart {
+			r = unicode.ToUpper(r)
+		}
+		wordStart = unicode.IsSpace(r)
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// --------------------------------------------------------------------------
+// percent fields: 12.5% is 0.125, or 12.5 with SetPercentAsRawNumber
+
+// fromPercent converts a percent cell, with or without the % sign, to the number
+// stored in the field. The decimal point is moved rather than divided by 100, so
+// that 7% is exactly 0.07.
+func fromPercent(value string) (string, error) {
+	s := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "%"))
+	if s == "" || percentAsRawNumber {
+		return s, nil
+	}
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return "", err
+		}
+		return toString(f / 100)
+	}
+	if _, err := strconv.ParseFloat(s, 64); err != nil {
+		return "", err
+	}
+	return shiftDecimalPoint(s, -2), nil
+}
+
+func getPercentFieldAsString(field reflect.Value) (string, error) {
+	for field.Kind() == reflect.Ptr || field.Kind() == reflect.Interface {
+		if field.IsNil() {
+			return "", nil
+		}
+		field = field.Elem()
+	}
+	if field.Kind() != reflect.Float32 && field.Kind() != reflect.Float64 {
+		return "", fmt.Errorf("cannot use percent with %s, only float supported", field.Type())
+	}
+	bitSize := 64
+	if field.Kind() == reflect.Float32 {
+		bitSize = 32
+	}
+	f := field.Float()
+	if !percentAsRawNumber {
+		// the decimal point is moved rather than multiplied by 100, so that 0.07 is 7
+		shifted := shiftDecimalPoint(strconv.FormatFloat(f, 'f', -1, bitSize), 2)
+		var err error
+		if f, err = strconv.ParseFloat(shifted, bitSize); err != nil {
+			return "", err
+		}
+	}
+	return formatFloat(f, bitSize) + "%", nil
+}
+
+// --------------------------------------------------------------------------
+// currency fields: $1,234.56 is 1234.56
+
+// isFloatType reports whether t, or the type t points to or holds the elements of, is
+// a float.
+func isFloatType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+}
+
+// isNumericType reports whether t, or the type t points to, is an int, uint or float.
+func isNumericType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// isBoolType reports whether t, or the type t points to, is a bool.
+func isBoolType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Bool
+}
+
+// fromCurrency converts an amount, like $1,234.56, -€50 or (£5) for an accounting
+// number, to the number parsed by strconv: the currency symbols set with
+// SetCurrencySymbols around it and the commas grouping its digits are dropped.
+func fromCurrency(value string) string {
+	s := strings.TrimSpace(value)
+	prefix, suffix := "", ""
+	if len(s) > 2 && s[0] == '(' && s[len(s)-1] == ')' {
+		prefix, suffix, s = "(", ")", s[1:len(s)-1]
+	}
+	trim := func(r rune) bool { return unicode.IsSpace(r) || strings.ContainsRune(string(currencySymbols), r) }
+	s = strings.TrimFunc(s, trim)
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		prefix, s = prefix+s[:1], strings.TrimFunc(s[1:], trim)
+	}
+	return prefix + strings.Replace(s, ",", "", -1) + suffix
+}
+
+// groupDigits inserts the separator set with SetDigitGroupSeparator between each group
+// of three digits of the integer part of the number s, e.g. 1,234,567.891.
+func groupDigits(s string) string {
+	start := strings.IndexFunc(s, func(r rune) bool { return r >= '0' && r <= '9' })
+	if start < 0 {
+		return s // NaN or Inf
+	}
+	end := start
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	var b strings.Builder
+	b.WriteString(s[:start])
+	for i := start; i < end; i++ {
+		if i > start && (end-i)%3 == 0 {
+			b.WriteRune(digitGroupSeparator)
+		}
+		b.WriteByte(s[i])
+	}
+	if end < len(s) && s[end] == '.' {
+		b.WriteRune(groupedDecimalMark())
+		end++
+	}
+	b.WriteString(s[end:])
+	return b.String()
+}
+
+// ungroupDigits removes the separator set with SetDigitGroupSeparator from the integer
+// part of the number s, up to its decimal mark, which it replaces with a point. The
+// separators after the decimal mark are left, so that such cells fail to parse.
+func ungroupDigits(s string) string {
+	integer, fraction := s, ""
+	if i := strings.IndexRune(s, groupedDecimalMark()); i >= 0 {
+		integer, fraction = s[:i], "."+s[i+utf8.RuneLen(groupedDecimalMark()):]
+	}
+	return strings.Replace(integer, string(digitGroupSeparator), "", -1) + fraction
+}
+
+// groupedDecimalMark returns the decimal mark of the fields tagged with group: the
+// comma when the digits are grouped with points, the point otherwise.
+func groupedDecimalMark() rune {
+	if digitGroupSeparator == '.' {
+		return ','
+	}
+	return '.'
+}
+
+// toCurrency writes symbol before the number s, after its sign or opening parenthesis.
+func toCurrency(s, symbol string) string {
+	if s == "" {
+		return s
+	}
+	if s[0] == '-' || s[0] == '(' {
+		return s[:1] + symbol + s[1:]
+	}
+	return symbol + s
+}
+
+// --------------------------------------------------------------------------
+// ISO 8601 duration fields: PT1H30M is 90 minutes
+
+// iso8601Units are the durations of the ISO 8601 designators, after T for the time ones.
+// Years and months have no fixed duration and are not supported.
+var iso8601Units = map[bool]map[byte]time.Duration{
+	false: {'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour},
+	true:  {'H': time.Hour, 'M': time.Minute, 'S': time.Second},
+}
+
+// fromISO8601Duration converts an ISO 8601 duration cell, like PT1H30M or -P1DT0.5S,
+// to the number of nanoseconds stored in the field.
+func fromISO8601Duration(value string) (string, error) {
+	s := strings.TrimSpace(value)
+	if s == "" {
+		return s, nil
+	}
+	negative := s[0] == '-'
+	if negative || s[0] == '+' {
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") || len(s) == 1 {
+		return "", invalidISO8601Duration(value)
+	}
+	s = s[1:]
+	var d time.Duration
+	inTime := false
+	for s != "" {
+		if s[0] == 'T' {
+			if inTime || len(s) == 1 {
+				return "", invalidISO8601Duration(value)
+			}
+			inTime = true
+			s = s[1:]
+			continue
+		}
+		n := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' && r != ',' })
+		if n <= 0 {
+			return "", invalidISO8601Duration(value)
+		}
+		unit, ok := iso8601Units[inTime][s[n]]
+		if !ok {
+			return "", invalidISO8601Duration(value)
+		}
+		f, err := strconv.ParseFloat(strings.Replace(s[:n], ",", ".", 1), 64)
+		if err != nil {
+			return "", invalidISO8601Duration(value)
+		}
+		d += time.Duration(f * float64(unit))
+		s = s[n+1:]
+	}
+	if negative {
+		d = -d
+	}
+	return strconv.FormatInt(int64(d), 10), nil
+}
+
+func invalidISO8601Duration(value string) error {
+	return fmt.Errorf("invalid ISO 8601 duration %q", value)
+}
+
+func getISO8601FieldAsString(field reflect.Value) (string, error) {
+	for field.Kind() == reflect.Ptr || field.Kind() == reflect.Interface {
+		if field.IsNil() {
+			return "", nil
+		}
+		field = field.Elem()
+	}
+	if field.Kind() != reflect.Int64 {
+		return "", fmt.Errorf("cannot use iso8601 with %s, only time.Duration supported", field.Type())
+	}
+	d := time.Duration(field.Int())
+	if d == 0 {
+		return "PT0S", nil
+	}
+	var b strings.Builder
+	if d < 0 {
+		b.WriteByte('-')
+		d = -d
+	}
+	b.WriteString("PT")
+	if h := d / time.Hour; h > 0 {
+		b.WriteString(strconv.FormatInt(int64(h), 10) + "H")
+		d -= h * time.Hour
+	}
+	if m := d / time.Minute; m > 0 {
+		b.WriteString(strconv.FormatInt(int64(m), 10) + "M")
+		d -= m * time.Minute
+	}
+	if d > 0 {
+		b.WriteString(strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S")
+	}
+	return b.String(), nil
+}
+
+// shiftDecimalPoint moves the decimal point of the decimal number s by n digits,
+// to the right when n is positive.
+func shiftDecimalPoint(s string, n int) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, s = s[:1], s[1:]
+		if sign == "+" {
+			sign = ""
+		}
+	}
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	digits := intPart + fracPart
+	point := len(intPart) + n
+	switch {
+	case point <= 0:
+		digits = "0." + strings.Repeat("0", -point) + digits
+	case point >= len(digits):
+		digits += strings.Repeat("0", point-len(digits))
+	default:
+		digits = digits[:point] + "." + digits[point:]
+	}
+	if strings.Contains(digits, ".") {
+		digits = strings.TrimRight(strings.TrimRight(digits, "0"), ".")
+	}
+	digits = strings.TrimLeft(digits, "0")
+	if digits == "" || digits[0] == '.' {
+		digits = "0" + digits
+	}
+	if digits == "0" {
+		sign = ""
+	}
+	return sign + digits
+}
+
+// --------------------------------------------------------------------------
+// jsonarray fields: the cell is the JSON array of the slice, a nil slice is an empty cell
+
+func setJSONArrayField(field reflect.Value, value string) error {
+	if value == "" {
+		if emptyJSONArrayAsEmptySlice && field.Kind() == reflect.Slice {
+			field.Set(reflect.MakeSlice(field.Type(), 0, 0))
+		} else {
+			field.Set(reflect.Zero(field.Type()))
+		}
+		return nil
+	}
+	return json.Unmarshal([]byte(value), field.Addr().Interface())
+}
+
+func getJSONArrayFieldAsString(field reflect.Value) (string, error) {
+	if (field.Kind() == reflect.Slice || field.Kind() == reflect.Ptr) && field.IsNil() {
+		return "", nil
+	}
+	b, err := json.Marshal(field.Interface())
+	return string(b), err
+}
+
+// --------------------------------------------------------------------------
+// json fields: the cell is the JSON of the field, a nil field is an empty cell
+
+func setJSONField(field reflect.Value, value string) error {
+	if value == "" {
+		if field.Kind() == reflect.Ptr && !emptyJSONAsNil {
+			field.Set(reflect.New(field.Type().Elem()))
+		} else {
+			field.Set(reflect.Zero(field.Type()))
+		}
+		return nil
+	}
+	return json.Unmarshal([]byte(value), field.Addr().Interface())
+}
+
+func getJSONFieldAsString(field reflect.Value) (string, error) {
+	switch field.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		if field.IsNil() {
+			return "", nil
+		}
+	}
+	b, err := json.Marshal(field.Interface())
+	return string(b), err
+}
+
+// --------------------------------------------------------------------------
+// sep fields: the cell joins the elements of the slice with the separator, an element
+// holding it or a backslash escapes them with a backslash, and a nil slice is an empty cell,
+// as is a nil pointer to a slice
+
+func setSeparatedField(field reflect.Value, value, sep string) error {
+	if field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.Slice {
+		if value == "" {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		slice := reflect.New(field.Type().Elem())
+		if err := setSeparatedField(slice.Elem(), value, sep); err != nil {
+			return err
+		}
+		field.Set(slice)
+		return nil
+	}
+	if field.Kind() != reflect.Slice {
+		return fmt.Errorf("cannot use sep with %s, only slice supported", field.Type())
+	}
+	if value == "" {
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	}
+	elems := splitEscaped(value, sep)
+	slice := reflect.MakeSlice(field.Type(), len(elems), len(elems))
+	for i, elem := range elems {
+		if err := setField(slice.Index(i), elem, false); err != nil {
+			return err
+		}
+	}
+	field.Set(slice)
+	return nil
+}
+
+func getSeparatedFieldAsString(field reflect.Value, sep string) (string, error) {
+	for field.Kind() == reflect.Ptr || field.Kind() == reflect.Interface {
+		if field.IsNil() {
+			return "", nil
+		}
+		field = field.Elem()
+	}
+	if field.Kind() != reflect.Slice {
+		return "", fmt.Errorf("cannot use sep with %s, only slice supported", field.Type())
+	}
+	var b strings.Builder
+	for i := 0; i < field.Len(); i++ {
+		elem, err := getFieldAsString(field.Index(i))
+		if err != nil {
+			return "", err
+		}
+		if i > 0 {
+			b.WriteString(sep)
+		}
+		elem = strings.Replace(elem, `\`, `\\`, -1)
+		b.WriteString(strings.Replace(elem, sep, `\`+sep, -1))
+	}
+	return b.String(), nil
+}
+
+// splitEscaped splits s on sep, like strings.Split, but keeps a separator or a
+// backslash escaped by a backslash.
+func splitEscaped(s, sep string) []string {
+	var elems []string
+	var elem strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == '\\' && strings.HasPrefix(s[i+1:], sep):
+			elem.WriteString(sep)
+			i += 1 + len(sep)
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == '\\':
+			elem.WriteByte('\\')
+			i += 2
+		case strings.HasPrefix(s[i:], sep):
+			elems = append(elems, elem.String())
+			elem.Reset()
+			i += len(sep)
+		default:
+			elem.WriteByte(s[i])
+			i++
+		}
+	}
+	return append(elems, elem.String())
+}
+
+var multiFieldMarshallerType = reflect.TypeOf((*MultiFieldMarshaller)(nil)).Elem()
+
+// multiFieldHeaders returns the column header suffixes of the type t, or nil when
+// t does not implement MultiFieldMarshaller
+func multiFieldHeaders(t reflect.Type) []string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if !reflect.PtrTo(t).Implements(multiFieldMarshallerType) {
+		return nil
+	}
+	return reflect.New(t).Interface().(MultiFieldMarshaller).CSVHeaders()
+}
+
+func marshallMulti(field reflect.Value, index int) (string, error) {
+	cells, t, err := marshallMultiCells(field)
+	return multiCell(cells, t, err, index)
+}
+
+// marshallMultiCells returns the cells of the MultiFieldMarshaller field, none for a nil
+// one, along with the type implementing it.
+func marshallMultiCells(field reflect.Value) ([]string, reflect.Type, error) {
+	for field.Kind() == reflect.Interface || field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil, nil, nil
+		}
+		field = field.Elem()
+	}
+	if field.CanAddr() {
+		field = field.Addr()
+	}
+	marshaller, ok := field.Interface().(MultiFieldMarshaller)
+	if !ok {
+		return nil, field.Type(), NoMarshalFuncError{field.Type()}
+	}
+	cells, err := marshalCSVMulti(marshaller)
+	return cells, field.Type(), err
+}
+
+// multiCell returns the cell index of the cells returned by marshallMultiCells.
+func multiCell(cells []string, t reflect.Type, err error, index int) (string, error) {
+	if err != nil || t == nil {
+		return "", err
+	}
+	if index >= len(cells) {
+		return "", fmt.Errorf("%s.MarshalCSVMulti returned %d cells, expected at least %d", t, len(cells), index+1)
+	}
+	return cells[index], nil
+}
+
+// multiCells keeps the cells of the MultiFieldMarshaller fields of a row, so that
+// MarshalCSVMulti is called once per field and row rather than once per column. The
+// fields are told apart by their index chain.
+type multiCells struct {
+	chains [][]int
+	cells  [][]string
+	types  []reflect.Type
+	errs   []error
+}
+
+// reset forgets the cells of the previous row.
+func (m *multiCells) reset() {
+	m.chains, m.cells, m.types, m.errs = m.chains[:0], m.cells[:0], m.types[:0], m.errs[:0]
+}
+
+// get returns the cell of the column fieldInfo of the field, calling MarshalCSVMulti
+// the first time the field is met in the row.
+func (m *multiCells) get(field reflect.Value, fieldInfo *fieldInfo) (string, error) {
+	for i, chain := range m.chains {
+		if equalIndexChains(chain, fieldInfo.IndexChain) {
+			return multiCell(m.cells[i], m.types[i], m.errs[i], fieldInfo.multiIndex)
+		}
+	}
+	cells, t, err := marshallMultiCells(field)
+	m.chains = append(m.chains, fieldInfo.IndexChain)
+	m.cells = append(m.cells, cells)
+	m.types = append(m.types, t)
+	m.errs = append(m.errs, err)
+	return multiCell(cells, t, err, fieldInfo.multiIndex)
+}
+
+func equalIndexChains(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// marshalCSVMulti calls MarshalCSVMulti, recovering from its panics when set with
+// SetRecoverFromPanics.
+func marshalCSVMulti(marshaller MultiFieldMarshaller) (cells []string, err error) {
+	if recoverFromPanics {
+		defer recoverPanic(reflect.TypeOf(marshaller), &err)
+	}
+	return marshaller.MarshalCSVMulti()
+}
+
+var emptyCheckerType = reflect.TypeOf((*TypeEmptyChecker)(nil)).Elem()
+
+func implementsEmptyChecker(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Implements(emptyCheckerType) || reflect.PtrTo(t).Implements(emptyCheckerType)
+}
+
+func isCSVEmpty(field reflect.Value) bool {
+	if field.CanAddr() {
+		field = field.Addr()
+	}
+	if field.CanInterface() {
+		if emptyChecker, ok := field.Interface().(TypeEmptyChecker); ok {
+			return emptyChecker.IsCSVEmpty()
+		}
+	}
+	return false
 }
 
 func unmarshall(field reflect.Value, value string) error {
 	dupField := field
-	unMarshallIt := func(finalField reflect.Value) error {
+	unMarshallIt := func(finalField reflect.Value) (err error) {
+		if recoverFromPanics {
+			defer recoverPanic(finalField.Type(), &err)
+		}
 		if finalField.CanInterface() {
 			fieldIface := finalField.Interface()
 
 			fieldTypeUnmarshaller, ok := fieldIface.(TypeUnmarshaller)
 			if ok {
-				return fieldTypeUnmarshaller.UnmarshalCSV(value)
+				return newUnmarshallerError(fieldTypeUnmarshaller.UnmarshalCSV(value))
 			}
 
 			// Otherwise try to use TextUnmarshaler
 			fieldTextUnmarshaler, ok := fieldIface.(encoding.TextUnmarshaler)
 			if ok {
-				return fieldTextUnmarshaler.UnmarshalText([]byte(value))
+				return newUnmarshallerError(fieldTextUnmarshaler.UnmarshalText([]byte(value)))
 			}
 		}
 
 		return NoUnmarshalFuncError{"No known conversion from string to " + field.Type().String() + ", " + field.Type().String() + " does not implement TypeUnmarshaller"}
 	}
 	for dupField.Kind() == reflect.Interface || dupField.Kind() == reflect.Ptr {
+		if dupField.Kind() == reflect.Interface && dupField.IsNil() {
+			// there is no dynamic type to decode the value in
+			return NoUnmarshalFuncError{"No known conversion from string to the nil " + dupField.Type().String()}
+		}
 		if dupField.IsNil() {
 			dupField = reflect.New(field.Type().Elem())
 			field.Set(dupField)
@@ -416,9 +1650,56 @@ func unmarshall(field reflect.Value, value string) error {
 	return NoUnmarshalFuncError{"No known conversion from string to " + field.Type().String() + ", " + field.Type().String() + " does not implement TypeUnmarshaller"}
 }
 
+// unmarshallerError marks the error returned by a custom unmarshaller, which is
+// the Err of the *csv.ParseError reporting it
+type unmarshallerError struct {
+	err error
+}
+
+func newUnmarshallerError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return unmarshallerError{err}
+}
+
+func (e unmarshallerError) Error() string {
+	return e.err.Error()
+}
+
+func (e unmarshallerError) Unwrap() error {
+	return e.err
+}
+
+// recoverPanic turns a panic of a custom (un)marshaller of t into *err.
+func recoverPanic(t reflect.Type, err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("panic in custom conversion of %s: %v", t, r)
+	}
+}
+
+// recoverCallbackPanic turns a panic of the function set with setter into *err.
+func recoverCallbackPanic(setter string, err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("panic in the function set with %s: %v", setter, r)
+	}
+}
+
+// unmarshalCSVWithFields calls UnmarshalCSVWithFields, recovering from its panics
+// when set with SetRecoverFromPanics.
+func unmarshalCSVWithFields(u TypeUnmarshalCSVWithFields, key, value string) (err error) {
+	if recoverFromPanics {
+		defer recoverPanic(reflect.TypeOf(u), &err)
+	}
+	return u.UnmarshalCSVWithFields(key, value)
+}
+
 func marshall(field reflect.Value) (value string, err error) {
 	dupField := field
-	marshallIt := func(finalField reflect.Value) (string, error) {
+	marshallIt := func(finalField reflect.Value) (value string, err error) {
+		if recoverFromPanics {
+			defer recoverPanic(finalField.Type(), &err)
+		}
 		if finalField.CanInterface() {
 			fieldIface := finalField.Interface()
 
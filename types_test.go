@@ -1,8 +1,15 @@
 package gocsv
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 type sampleTypeUnmarshaller struct {
@@ -139,3 +146,844 @@ func TestToInt(t *testing.T) {
 		}
 	}
 }
+
+type samplePtrMarshaller struct {
+	val string
+}
+
+func (s *samplePtrMarshaller) MarshalCSV() (string, error) {
+	return "ptr:" + s.val, nil
+}
+
+func Test_getFieldAsString_Interface(t *testing.T) {
+	type holder struct {
+		V interface{}
+	}
+	var nilIntPtr *int
+	tests := []struct {
+		in       interface{}
+		expected string
+	}{
+		{nil, ""},
+		{42, "42"},
+		{int8(-3), "-3"},
+		{uint(7), "7"},
+		{1.5, "1.5"},
+		{float32(0.25), "0.25"},
+		{"foo", "foo"},
+		{true, "true"},
+		{sampleStringer("bar"), "bar"},
+		{sampleTypeUnmarshaller{"baz"}, "baz"},
+		{samplePtrMarshaller{"qux"}, "ptr:qux"},
+		{&samplePtrMarshaller{"quux"}, "ptr:quux"},
+		{nilIntPtr, ""},
+	}
+	for _, test := range tests {
+		h := holder{V: test.in}
+		s, err := getFieldAsString(reflect.ValueOf(h).Field(0))
+		if err != nil {
+			t.Fatalf("getFieldAsString failure for %#v: %s", test.in, err)
+		}
+		if s != test.expected {
+			t.Fatalf("expected %q for %#v, got %q", test.expected, test.in, s)
+		}
+	}
+}
+
+// sampleEmptyChecker fails to unmarshal an empty cell, it relies on IsCSVEmpty instead.
+type sampleEmptyChecker struct {
+	val int
+}
+
+func (s sampleEmptyChecker) IsCSVEmpty() bool {
+	return s.val == 0
+}
+
+func (s sampleEmptyChecker) MarshalCSV() (string, error) {
+	return strconv.Itoa(s.val), nil
+}
+
+func (s *sampleEmptyChecker) UnmarshalCSV(val string) (err error) {
+	s.val, err = strconv.Atoi(val)
+	return err
+}
+
+func TestTypeEmptyChecker(t *testing.T) {
+	type emptyCheckerSample struct {
+		Value sampleEmptyChecker  `csv:"value"`
+		Ptr   *sampleEmptyChecker `csv:"ptr"`
+	}
+	s := []emptyCheckerSample{
+		{Value: sampleEmptyChecker{0}, Ptr: &sampleEmptyChecker{0}},
+		{Value: sampleEmptyChecker{2}, Ptr: &sampleEmptyChecker{3}},
+	}
+	csvContent, err := MarshalString(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if csvContent != "value,ptr\n,\n2,3\n" {
+		t.Fatalf("unexpected csv %q", csvContent)
+	}
+
+	var out []emptyCheckerSample
+	if err := UnmarshalString(csvContent, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !out[0].Value.IsCSVEmpty() || out[0].Ptr != nil {
+		t.Fatalf("expected empty values for empty cells, got %+v", out[0])
+	}
+	if out[1].Value.val != 2 || out[1].Ptr == nil || out[1].Ptr.val != 3 {
+		t.Fatalf("unexpected second row %+v", out[1])
+	}
+}
+
+func TestSQLNullTypes(t *testing.T) {
+	type sqlNullSample struct {
+		String  sql.NullString  `csv:"string"`
+		Int64   sql.NullInt64   `csv:"int64"`
+		Float64 sql.NullFloat64 `csv:"float64"`
+		Bool    sql.NullBool    `csv:"bool"`
+		Time    sql.NullTime    `csv:"time"`
+	}
+	date := time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	s := []sqlNullSample{
+		{
+			String:  sql.NullString{String: "foo", Valid: true},
+			Int64:   sql.NullInt64{Int64: 42, Valid: true},
+			Float64: sql.NullFloat64{Float64: 1.5, Valid: true},
+			Bool:    sql.NullBool{Bool: false, Valid: true},
+			Time:    sql.NullTime{Time: date, Valid: true},
+		},
+		{},
+	}
+	csvContent, err := MarshalString(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "string,int64,float64,bool,time\nfoo,42,1.5,false,2020-03-04T05:06:07Z\n,,,,\n"
+	if csvContent != expected {
+		t.Fatalf("expected %q, got %q", expected, csvContent)
+	}
+
+	var out []sqlNullSample
+	if err := UnmarshalString(csvContent, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, out) {
+		t.Fatalf("expected %+v, got %+v", s, out)
+	}
+}
+
+func TestSetTimeLocation(t *testing.T) {
+	paris := time.FixedZone("CET", 3600)
+	SetTimeLocation(paris)
+	defer SetTimeLocation(nil)
+
+	type timeSample struct {
+		At   time.Time    `csv:"at"`
+		Ptr  *time.Time   `csv:"ptr"`
+		Null sql.NullTime `csv:"null"`
+	}
+	at := time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	out, err := MarshalString([]timeSample{{At: at, Ptr: &at, Null: sql.NullTime{Time: at, Valid: true}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "at,ptr,null\n2020-03-04T06:06:07+01:00,2020-03-04T06:06:07+01:00,2020-03-04T06:06:07+01:00\n"
+	if out != expected {
+		t.Fatalf("expected %q, got %q", expected, out)
+	}
+
+	var samples []timeSample
+	if err := UnmarshalString("at,ptr,null\n2020-03-04T05:06:07Z,2020-03-04T06:06:07,2020-03-04T06:06:07\n", &samples); err != nil {
+		t.Fatal(err)
+	}
+	s := samples[0]
+	if !s.At.Equal(at) || s.At.Location() != paris {
+		t.Errorf("expected %v in %v, got %v", at, paris, s.At)
+	}
+	if !s.Ptr.Equal(at) || !s.Null.Time.Equal(at) {
+		t.Errorf("expected the zone-less times to be read in %v, got %v and %v", paris, s.Ptr, s.Null.Time)
+	}
+
+	SetTimeLocation(nil)
+	if err := UnmarshalString("at\n2020-03-04T06:06:07\n", &samples); err == nil {
+		t.Error("expected an error for a time without zone when no location is set")
+	}
+}
+
+func TestJSONArrayTag(t *testing.T) {
+	type point struct {
+		X int `json:"x"`
+	}
+	type jsonSample struct {
+		Name   string   `csv:"name"`
+		Tags   []string `csv:"tags,jsonarray"`
+		Points []point  `csv:"points,jsonarray"`
+	}
+	in := []jsonSample{
+		{Name: "a", Tags: []string{"x", "y,z"}, Points: []point{{1}, {2}}},
+		{Name: "b", Tags: []string{}},
+	}
+	out, err := MarshalString(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "name,tags,points\na,\"[\"\"x\"\",\"\"y,z\"\"]\",\"[{\"\"x\"\":1},{\"\"x\"\":2}]\"\nb,[],\n"
+	if out != expected {
+		t.Fatalf("expected %q, got %q", expected, out)
+	}
+
+	var samples []jsonSample
+	if err := UnmarshalString(out, &samples); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, samples) {
+		t.Fatalf("expected %v, got %v", in, samples)
+	}
+	if samples[1].Points != nil {
+		t.Errorf("expected a nil slice for an empty cell, got %#v", samples[1].Points)
+	}
+
+	SetEmptyJSONArrayAsEmptySlice(true)
+	defer SetEmptyJSONArrayAsEmptySlice(false)
+	samples = nil
+	if err := UnmarshalString("name,tags,points\nb,,\n", &samples); err != nil {
+		t.Fatal(err)
+	}
+	if samples[0].Tags == nil || len(samples[0].Tags) != 0 {
+		t.Errorf("expected an empty slice for an empty cell, got %#v", samples[0].Tags)
+	}
+}
+
+func TestBoolStringTags(t *testing.T) {
+	type boolSample struct {
+		Active  bool  `csv:"active,truestr:Active,falsestr:Inactive"`
+		Paid    *bool `csv:"paid,truestr:Y,falsestr:N"`
+		Default bool  `csv:"default"`
+	}
+	paid := true
+	out, err := MarshalString([]boolSample{{Active: true, Paid: &paid}, {}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "active,paid,default\nActive,Y,false\nInactive,,false\n"
+	if out != expected {
+		t.Fatalf("expected %q, got %q", expected, out)
+	}
+
+	var samples []boolSample
+	if err := UnmarshalString("active,paid,default\nActive,N,true\nInactive,Y,false\ntrue,,no\n", &samples); err != nil {
+		t.Fatal(err)
+	}
+	if !samples[0].Active || *samples[0].Paid || !samples[0].Default {
+		t.Errorf("unexpected first row %+v", samples[0])
+	}
+	if samples[1].Active || !*samples[1].Paid {
+		t.Errorf("unexpected second row %+v", samples[1])
+	}
+	// The default conversion still applies to other values.
+	if !samples[2].Active {
+		t.Errorf("expected true to be decoded with the default conversion, got %+v", samples[2])
+	}
+}
+
+func TestErrorField(t *testing.T) {
+	type result struct {
+		ID  int   `csv:"id"`
+		Err error `csv:"error"`
+	}
+	out, err := MarshalString([]result{{ID: 1}, {ID: 2, Err: errors.New("invalid email")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "id,error\n1,\n2,invalid email\n"
+	if out != expected {
+		t.Fatalf("expected %q, got %q", expected, out)
+	}
+
+	var results []result
+	if err := UnmarshalString(out, &results); err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Err != nil || results[1].Err == nil || results[1].Err.Error() != "invalid email" {
+		t.Fatalf("unexpected errors %v", results)
+	}
+}
+
+func TestFloatRoundTrip(t *testing.T) {
+	type floatSample struct {
+		F float64 `csv:"f"`
+	}
+	values := []float64{
+		0, math.Copysign(0, -1), 0.1, 1.0 / 3, -2.5e-8,
+		math.MaxFloat64, -math.MaxFloat64, math.SmallestNonzeroFloat64, 2.2250738585072014e-308,
+		1e21, 123456789012345678, math.Inf(1), math.Inf(-1),
+	}
+	defer SetFloatFormat('f', -1)
+	for _, format := range []byte{'f', 'g', 'e'} {
+		SetFloatFormat(format, -1)
+		for _, v := range values {
+			out, err := MarshalString([]floatSample{{v}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var samples []floatSample
+			if err := UnmarshalString(out, &samples); err != nil {
+				t.Fatalf("%c %v: %v", format, v, err)
+			}
+			if math.Float64bits(samples[0].F) != math.Float64bits(v) {
+				t.Errorf("%c: expected %v, got %v from %q", format, v, samples[0].F, out)
+			}
+		}
+	}
+
+	SetFloatFormat('g', -1)
+	if out, _ := MarshalString([]floatSample{{1e21}}); out != "f\n1e+21\n" {
+		t.Errorf("unexpected csv %q", out)
+	}
+	SetFloatFormat('f', 2)
+	if out, _ := MarshalString([]floatSample{{1.0 / 3}}); out != "f\n0.33\n" {
+		t.Errorf("unexpected csv %q", out)
+	}
+}
+
+func TestPercentTag(t *testing.T) {
+	type rateSample struct {
+		Rate  float64  `csv:"rate,percent"`
+		Small float32  `csv:"small,percent"`
+		Ptr   *float64 `csv:"ptr,percent,omitempty"`
+	}
+	var samples []rateSample
+	if err := UnmarshalString("rate,small,ptr\n12.5%,7%,\n-0.5 %,100,1e2%\n", &samples); err != nil {
+		t.Fatal(err)
+	}
+	if samples[0].Rate != 0.125 || samples[0].Small != 0.07 || samples[0].Ptr != nil {
+		t.Errorf("unexpected first row %+v", samples[0])
+	}
+	if samples[1].Rate != -0.005 || samples[1].Small != 1 || *samples[1].Ptr != 1 {
+		t.Errorf("unexpected second row %+v", samples[1])
+	}
+
+	out, err := MarshalString(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "rate,small,ptr\n12.5%,7%,\n-0.5%,100%,100%\n"
+	if out != expected {
+		t.Fatalf("expected %q, got %q", expected, out)
+	}
+
+	SetPercentAsRawNumber(true)
+	defer SetPercentAsRawNumber(false)
+	samples = nil
+	if err := UnmarshalString("rate,small,ptr\n12.5%,7%,\n", &samples); err != nil {
+		t.Fatal(err)
+	}
+	if samples[0].Rate != 12.5 || samples[0].Small != 7 {
+		t.Errorf("unexpected raw numbers %+v", samples[0])
+	}
+	if out, _ := MarshalString(samples); out != "rate,small,ptr\n12.5%,7%,\n" {
+		t.Errorf("unexpected csv %q", out)
+	}
+
+	if err := UnmarshalString("rate,small,ptr\nabc%,1,1\n", &samples); err == nil {
+		t.Error("expected an error for an invalid percentage")
+	}
+	SetPercentAsRawNumber(false)
+	SetFloatFormat('f', 2)
+	defer SetFloatFormat('f', -1)
+	if out, err := MarshalString([]rateSample{{Rate: 0.125, Small: 0.07}}); err != nil || out != "rate,small,ptr\n12.50%,7.00%,\n" {
+		t.Errorf("expected the float format to apply, got %q, %v", out, err)
+	}
+
+	type intRate struct {
+		Rate int `csv:"rate,percent"`
+	}
+	var intRates []intRate
+	if err := UnmarshalString("rate\n5%\n", &intRates); err == nil || !strings.Contains(err.Error(), "only float supported") {
+		t.Errorf("expected an error for a percent int field, got %v", err)
+	}
+}
+
+func TestIntOverflowBehavior(t *testing.T) {
+	type sizedSample struct {
+		Small int8   `csv:"small"`
+		Byte  uint8  `csv:"byte"`
+		Count uint16 `csv:"count"`
+	}
+	const in = "small,byte,count\n300,-1,70000\n-200,256,5\n"
+
+	var samples []sizedSample
+	err := UnmarshalString(in, &samples)
+	if !errors.Is(err, ErrIntOverflow) {
+		t.Fatalf("expected ErrIntOverflow, got %v", err)
+	}
+
+	SetIntOverflowBehavior(IntOverflowZero)
+	defer SetIntOverflowBehavior(IntOverflowError)
+	samples = nil
+	if err := UnmarshalString(in, &samples); err != nil {
+		t.Fatal(err)
+	}
+	if samples[0] != (sizedSample{0, 0, 0}) || samples[1] != (sizedSample{0, 0, 5}) {
+		t.Errorf("unexpected zeroed samples %+v", samples)
+	}
+
+	var warnings []*ConversionError
+	SetIntOverflowBehavior(IntOverflowClamp)
+	SetIntOverflowWarningHandler(func(err *ConversionError) {
+		warnings = append(warnings, err)
+	})
+	defer SetIntOverflowWarningHandler(nil)
+	samples = nil
+	if err := UnmarshalString(in, &samples); err != nil {
+		t.Fatal(err)
+	}
+	if samples[0] != (sizedSample{127, 0, 65535}) || samples[1] != (sizedSample{-128, 255, 5}) {
+		t.Errorf("unexpected clamped samples %+v", samples)
+	}
+	if len(warnings) != 5 {
+		t.Fatalf("expected 5 warnings, got %d", len(warnings))
+	}
+	if w := warnings[0]; w.Line != 2 || w.Column != "small" || w.Value != "300" || !errors.Is(w, ErrIntOverflowClamped) {
+		t.Errorf("unexpected warning %+v", w)
+	}
+
+	if err := UnmarshalString("small,byte,count\nabc,1,1\n", &samples); err == nil || errors.Is(err, ErrIntOverflow) {
+		t.Errorf("expected a syntax error, got %v", err)
+	}
+	if err := UnmarshalString("small,byte,count\n1,7.abc,1\n", &samples); err == nil || errors.Is(err, ErrIntOverflow) {
+		t.Errorf("expected a syntax error for garbage after the dot, got %v", err)
+	}
+	SetIntOverflowBehavior(IntOverflowZero)
+	if err := UnmarshalString("small,byte,count\n1,1,-7.abc\n", &samples); err == nil || errors.Is(err, ErrIntOverflow) {
+		t.Errorf("expected a syntax error for garbage after the dot, got %v", err)
+	}
+}
+
+func TestISO8601Tag(t *testing.T) {
+	type slot struct {
+		Length time.Duration  `csv:"length,iso8601"`
+		Break  *time.Duration `csv:"break,iso8601,omitempty"`
+	}
+	var slots []slot
+	if err := UnmarshalString("length,break\nPT1H30M,\nP1DT0.5S,-PT2M\nP1W,\"PT0,5S\"\n", &slots); err != nil {
+		t.Fatal(err)
+	}
+	if slots[0].Length != 90*time.Minute || slots[0].Break != nil {
+		t.Errorf("unexpected first row %+v", slots[0])
+	}
+	if slots[1].Length != 24*time.Hour+500*time.Millisecond || *slots[1].Break != -2*time.Minute {
+		t.Errorf("unexpected second row %+v", slots[1])
+	}
+	if slots[2].Length != 7*24*time.Hour || *slots[2].Break != 500*time.Millisecond {
+		t.Errorf("unexpected third row %+v", slots[2])
+	}
+
+	out, err := MarshalString(slots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "length,break\nPT1H30M,\nPT24H0.5S,-PT2M\nPT168H,PT0.5S\n"
+	if out != expected {
+		t.Fatalf("expected %q, got %q", expected, out)
+	}
+
+	for _, invalid := range []string{"1H", "P", "PT", "P1Y", "PT1D", "P1H", "PT1.5.5S"} {
+		if err := UnmarshalString("length,break\n"+invalid+",\n", &slots); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestAccountingNumbers(t *testing.T) {
+	type entry struct {
+		Amount float64 `csv:"amount"`
+		Count  int     `csv:"count"`
+		Units  uint    `csv:"units"`
+	}
+	const in = "amount,count,units\n(123.45),( 7 ),+3\n+5,-2,4\n"
+	var entries []entry
+	if err := UnmarshalString(in, &entries); err == nil {
+		t.Fatal("expected an error without accounting number parsing")
+	}
+
+	SetAccountingNumberParsing(true)
+	defer SetAccountingNumberParsing(false)
+	entries = nil
+	if err := UnmarshalString(in, &entries); err != nil {
+		t.Fatal(err)
+	}
+	if entries[0] != (entry{-123.45, -7, 3}) || entries[1] != (entry{5, -2, 4}) {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+
+	SetAccountingNegatives(true)
+	defer SetAccountingNegatives(false)
+	out, err := MarshalString(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "amount,count,units\n(123.45),-7,3\n5,-2,4\n"; out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestJSONTag(t *testing.T) {
+	type profile struct {
+		Age  int      `json:"age"`
+		Tags []string `json:"tags,omitempty"`
+	}
+	type user struct {
+		Name    string   `csv:"name"`
+		Profile profile  `csv:"profile,json"`
+		Extra   *profile `csv:"extra,json"`
+	}
+	in := "name,profile,extra\nann,\"{\"\"age\"\":30,\"\"tags\"\":[\"\"a\"\"]}\",\nbob,,\"{\"\"age\"\":4}\"\n"
+	var users []user
+	if err := UnmarshalString(in, &users); err != nil {
+		t.Fatal(err)
+	}
+	if users[0].Profile.Age != 30 || users[0].Profile.Tags[0] != "a" || users[0].Extra == nil || users[0].Extra.Age != 0 {
+		t.Errorf("unexpected first user %+v", users[0])
+	}
+	if users[1].Profile.Age != 0 || users[1].Extra.Age != 4 {
+		t.Errorf("unexpected second user %+v", users[1])
+	}
+
+	SetEmptyJSONAsNil(true)
+	defer SetEmptyJSONAsNil(false)
+	if err := UnmarshalString(in, &users); err != nil {
+		t.Fatal(err)
+	}
+	if users[0].Extra != nil {
+		t.Errorf("expected a nil profile, got %+v", users[0].Extra)
+	}
+
+	out, err := MarshalString(users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "name,profile,extra\nann,\"{\"\"age\"\":30,\"\"tags\"\":[\"\"a\"\"]}\",\nbob,\"{\"\"age\"\":0}\",\"{\"\"age\"\":4}\"\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	if err := UnmarshalString("name,profile,extra\nann,{,\n", &users); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestSeparatorTag(t *testing.T) {
+	type post struct {
+		Tags   []string  `csv:"tags,sep:|"`
+		Scores []int     `csv:"scores,sep:;"`
+		Ratios []float64 `csv:"ratios,sep:|"`
+	}
+	in := "tags,scores,ratios\na|b\\|c|d\\\\,1;2;3,0.5|2\n,,\n"
+	var posts []post
+	if err := UnmarshalString(in, &posts); err != nil {
+		t.Fatal(err)
+	}
+	expected := []post{{[]string{"a", "b|c", "d\\"}, []int{1, 2, 3}, []float64{0.5, 2}}, {}}
+	if !reflect.DeepEqual(posts, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, posts)
+	}
+
+	out, err := MarshalString(posts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Errorf("expected %q, got %q", in, out)
+	}
+
+	if err := UnmarshalString("tags,scores,ratios\n,1;x,\n", &posts); err == nil {
+		t.Error("expected an error for an invalid element")
+	}
+	type labelled struct {
+		Name   string    `csv:"name"`
+		Labels *[]string `csv:"labels,sep:|"`
+	}
+	var labels []labelled
+	if err := UnmarshalString("name,labels\na,a|b\nb,\n", &labels); err != nil {
+		t.Fatal(err)
+	}
+	if len(labels) != 2 || labels[0].Labels == nil || !reflect.DeepEqual(*labels[0].Labels, []string{"a", "b"}) || labels[1].Labels != nil {
+		t.Fatalf("unexpected labels %+v", labels)
+	}
+	if out, err := MarshalString(labels); err != nil || out != "name,labels\na,a|b\nb,\n" {
+		t.Errorf("unexpected csv %q, %v", out, err)
+	}
+}
+
+func TestSetTimeLayouts(t *testing.T) {
+	type event struct {
+		At time.Time `csv:"at"`
+	}
+	SetTimeLayouts([]string{time.RFC3339, "2006-01-02", "02/01/2006 15:04"})
+	defer SetTimeLayouts(nil)
+
+	var events []event
+	if err := UnmarshalString("at\n2020-03-04T05:06:07+01:00\n2020-03-04\n04/03/2020 05:06\n", &events); err != nil {
+		t.Fatal(err)
+	}
+	expected := []time.Time{
+		time.Date(2020, 3, 4, 4, 6, 7, 0, time.UTC),
+		time.Date(2020, 3, 4, 0, 0, 0, 0, time.UTC),
+		time.Date(2020, 3, 4, 5, 6, 0, 0, time.UTC),
+	}
+	for i, e := range events {
+		if !e.At.Equal(expected[i]) || e.At.Location() != time.UTC {
+			t.Errorf("expected %v, got %v", expected[i], e.At)
+		}
+	}
+
+	err := UnmarshalString("at\nMarch 4\n", &events)
+	if err == nil || !strings.Contains(err.Error(), `cannot parse "March 4"`) {
+		t.Errorf("expected an error for a time matching no layout, got %v", err)
+	}
+}
+
+func TestCurrencyFields(t *testing.T) {
+	type line struct {
+		Item  string   `csv:"item,currency"`
+		Price float64  `csv:"price,currency:$"`
+		Qty   *int     `csv:"qty,currency"`
+		Total *float32 `csv:"total,currency:€"`
+	}
+	var lines []line
+	in := "item,price,qty,total\n$hat,\"$1,234.56\",\"1,000\",€50\n-€ cap,-$2,£3,(€4.5)\n"
+	SetAccountingNumberParsing(true)
+	defer SetAccountingNumberParsing(false)
+	if err := UnmarshalString(in, &lines); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	if lines[0].Item != "$hat" || lines[0].Price != 1234.56 || *lines[0].Qty != 1000 || *lines[0].Total != 50 {
+		t.Errorf("unexpected first line %+v", lines[0])
+	}
+	if lines[1].Item != "-€ cap" || lines[1].Price != -2 || *lines[1].Qty != 3 || *lines[1].Total != -4.5 {
+		t.Errorf("unexpected second line %+v", lines[1])
+	}
+
+	out, err := MarshalString(lines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "item,price,qty,total\n$hat,$1234.56,1000,€50\n-€ cap,-$2,3,-€4.5\n"; out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	SetCurrencySymbols([]rune("¤"))
+	defer SetCurrencySymbols([]rune("$€£¥"))
+	if err := UnmarshalString("item,price,qty,total\na,$1,1,1\n", &lines); err == nil {
+		t.Error("expected an error for a symbol that is not set")
+	}
+}
+
+func TestSetZeroTimeAsEmpty(t *testing.T) {
+	type event struct {
+		Name string     `csv:"name"`
+		At   time.Time  `csv:"at"`
+		End  *time.Time `csv:"end"`
+	}
+	end := time.Date(2020, 3, 4, 0, 0, 0, 0, time.UTC)
+	events := []event{{Name: "a", End: &time.Time{}}, {Name: "b", At: end, End: &end}}
+
+	SetZeroTimeAsEmpty(true)
+	defer SetZeroTimeAsEmpty(false)
+	out, err := MarshalString(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "name,at,end\na,,\nb,2020-03-04T00:00:00Z,2020-03-04T00:00:00Z\n"; out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	SetTimeLayouts([]string{"2006-01-02T15:04:05Z07:00"})
+	defer SetTimeLayouts(nil)
+	var decoded []event
+	if err := UnmarshalString(out, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded[0].At.IsZero() || decoded[0].End == nil || !decoded[0].End.IsZero() || !decoded[1].End.Equal(end) {
+		t.Errorf("unexpected events %+v", decoded)
+	}
+
+	SetZeroTimeAsEmpty(false)
+	if err := UnmarshalString(out, &decoded); err == nil {
+		t.Error("expected an error for an empty time without SetZeroTimeAsEmpty")
+	}
+}
+
+func TestPresenceFields(t *testing.T) {
+	type item struct {
+		Name     string `csv:"name"`
+		Flagged  bool   `csv:"flagged,presence"`
+		Archived *bool  `csv:"archived,presence,truestr:yes"`
+	}
+	var items []item
+	if err := UnmarshalString("name,flagged,archived\na,X,\nb, ,no\nc,,\n", &items); err != nil {
+		t.Fatal(err)
+	}
+	if !items[0].Flagged || *items[0].Archived || items[1].Flagged || !*items[1].Archived || items[2].Flagged {
+		t.Errorf("unexpected items %+v", items)
+	}
+
+	SetPresenceMarker("*")
+	defer SetPresenceMarker("X")
+	items[2].Archived = nil
+	out, err := MarshalString(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "name,flagged,archived\na,*,\nb,,yes\nc,,\n"; out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+func TestJSONRawMessageFields(t *testing.T) {
+	type event struct {
+		Name    string           `csv:"name"`
+		Payload json.RawMessage  `csv:"payload"`
+		Extra   *json.RawMessage `csv:"extra"`
+	}
+	extra := json.RawMessage(`[1, 2]`)
+	events := []event{{Name: "a", Payload: json.RawMessage(`{"b": [true]}`), Extra: &extra}, {Name: "c"}}
+	out, err := MarshalString(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "name,payload,extra\na,\"{\"\"b\"\": [true]}\",\"[1, 2]\"\nc,,\n"; out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	var decoded []event
+	if err := UnmarshalString(out+"d,not json,\n", &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if string(decoded[0].Payload) != `{"b": [true]}` || string(*decoded[0].Extra) != "[1, 2]" {
+		t.Errorf("unexpected first event %+v", decoded[0])
+	}
+	if decoded[1].Payload != nil || string(decoded[2].Payload) != "not json" {
+		t.Errorf("unexpected events %+v", decoded[1:])
+	}
+}
+
+func TestBoolLocales(t *testing.T) {
+	type answer struct {
+		Q string `csv:"q"`
+		A bool   `csv:"a"`
+	}
+	RegisterBoolLocale("fr", []string{"oui", "vrai"}, []string{"non", "faux"})
+	if err := SetBoolLocale("xx"); err == nil {
+		t.Error("expected an error for an unregistered locale")
+	}
+	if err := SetBoolLocale("fr"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetBoolLocale("")
+
+	var answers []answer
+	if err := UnmarshalString("q,a\n1,OUI\n2,faux\n3,\n4, Vrai \n", &answers); err != nil {
+		t.Fatal(err)
+	}
+	if !answers[0].A || answers[1].A || answers[2].A || !answers[3].A {
+		t.Errorf("unexpected answers %+v", answers)
+	}
+	err := UnmarshalString("q,a\n1,true\n", &answers)
+	if err == nil || !strings.Contains(err.Error(), `expected one of ["oui" "vrai"] or ["non" "faux"]`) {
+		t.Errorf("expected an error listing the words of the locale, got %v", err)
+	}
+
+	out, err := MarshalString(answers[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "q,a\n1,oui\n2,non\n" {
+		t.Errorf("unexpected csv %q", out)
+	}
+}
+
+func TestFlexibleIntParsing(t *testing.T) {
+	type row struct {
+		Count int64  `csv:"count"`
+		Size  uint16 `csv:"size"`
+	}
+	var rows []row
+	if err := UnmarshalString("count,size\n1e3,2E2\n", &rows); err == nil {
+		t.Fatal("expected an error without flexible int parsing")
+	}
+
+	SetFlexibleIntParsing(true)
+	defer SetFlexibleIntParsing(false)
+	rows = nil
+	if err := UnmarshalString("count,size\n1e3,2E2\n-1.5e1,0x1E\n", &rows); err != nil {
+		t.Fatal(err)
+	}
+	expected := []row{{Count: 1000, Size: 200}, {Count: -15, Size: 30}}
+	if !reflect.DeepEqual(expected, rows) {
+		t.Fatalf("expected %v, got %v", expected, rows)
+	}
+
+	for _, in := range []string{"count,size\n1.5e0,1\n", "count,size\n1,1e5\n", "count,size\n1,-1e2\n", "count,size\n1e19,1\n"} {
+		rows = nil
+		if err := UnmarshalString(in, &rows); err == nil {
+			t.Errorf("expected an error for %q, got %v", in, rows)
+		}
+	}
+}
+
+func TestGroupFields(t *testing.T) {
+	type country struct {
+		Name       string   `csv:"name"`
+		Population int64    `csv:"population,group"`
+		Area       *float64 `csv:"area,group"`
+		Code       int      `csv:"code"`
+	}
+	area := -1234567.5
+	countries := []country{{"a", 1234567, &area, 1000}, {"b", 999, nil, 2}}
+	out, err := MarshalString(countries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "name,population,area,code\na,\"1,234,567\",\"-1,234,567.5\",1000\nb,999,,2\n"; out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+	var decoded []country
+	if err := UnmarshalString(out, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded[0].Population != 1234567 || *decoded[0].Area != area || decoded[1].Population != 999 {
+		t.Errorf("unexpected countries %+v", decoded)
+	}
+	if err := UnmarshalString("name,population,area,code\na,1,2,\"1,000\"\n", &decoded); err == nil {
+		t.Error("expected an error for a grouped field without the group tag option")
+	}
+
+	SetDigitGroupSeparator(' ')
+	defer SetDigitGroupSeparator(',')
+	if out, err = MarshalString(countries[:1]); err != nil || out != "name,population,area,code\na,1 234 567,-1 234 567.5,1000\n" {
+		t.Errorf("unexpected csv %q, %v", out, err)
+	}
+
+	SetDigitGroupSeparator('.')
+	area = 1234567.5
+	if out, err = MarshalString(countries[:1]); err != nil || out != "name,population,area,code\na,1.234.567,\"1.234.567,5\",1000\n" {
+		t.Errorf("unexpected csv %q, %v", out, err)
+	}
+	decoded = nil
+	if err := UnmarshalString(out, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded[0].Population != 1234567 || *decoded[0].Area != area {
+		t.Errorf("unexpected countries %+v", decoded)
+	}
+	if err := UnmarshalString("name,population,area,code\na,1,\"1,234.5\",1\n", &decoded); err == nil {
+		t.Error("expected an error for a separator after the decimal mark")
+	}
+}
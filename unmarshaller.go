@@ -3,6 +3,7 @@ package gocsv
 import (
 	"encoding/csv"
 	"fmt"
+	"io"
 	"reflect"
 )
 
@@ -15,6 +16,11 @@ type Unmarshaller struct {
 	MismatchedStructFields []string
 	outType                reflect.Type
 	out                    interface{}
+	lastRowOffset          int64
+	fieldDecoders          map[string]func(string) (interface{}, error)
+	rowsRead               int // data rows read, in or out of the range
+	rowStart, rowEnd       int // set with SetRowRange, rowEnd < 0 for no end
+	tagSeparator           string
 }
 
 // NewUnmarshaller creates an unmarshaller from a csv.Reader and a struct.
@@ -25,7 +31,7 @@ func NewUnmarshaller(reader *csv.Reader, out interface{}) (*Unmarshaller, error)
 	}
 	headers = normalizeHeaders(headers)
 
-	um := &Unmarshaller{reader: reader, outType: reflect.TypeOf(out)}
+	um := &Unmarshaller{reader: reader, outType: reflect.TypeOf(out), lastRowOffset: -1, rowEnd: -1}
 	err = validate(um, out, headers)
 	if err != nil {
 		return nil, err
@@ -33,10 +39,44 @@ func NewUnmarshaller(reader *csv.Reader, out interface{}) (*Unmarshaller, error)
 	return um, nil
 }
 
+// NewUnmarshallerWithHeaders creates an unmarshaller from the headers of a CSV and a
+// struct, for records read separately, e.g. CSV chunks of which only the first holds
+// the header. The records are decoded with DecodeRecords, Read cannot be used.
+func NewUnmarshallerWithHeaders(headers []string, out interface{}) (*Unmarshaller, error) {
+	um := &Unmarshaller{outType: reflect.TypeOf(out), lastRowOffset: -1, rowEnd: -1}
+	if err := validate(um, out, normalizeHeaders(headers)); err != nil {
+		return nil, err
+	}
+	return um, nil
+}
+
+// DecodeRecords decodes records, CSV rows without the header, with the header mapping
+// of the Unmarshaller. out must be a pointer to a slice of the struct type used to
+// create the Unmarshaller, its content is replaced by one value per record.
+func (um *Unmarshaller) DecodeRecords(records [][]string, out interface{}) error {
+	outValue := reflect.ValueOf(out)
+	if outValue.Kind() != reflect.Ptr || outValue.Elem().Kind() != reflect.Slice || outValue.Elem().Type().Elem() != um.outType {
+		return fmt.Errorf("cannot use %T, only pointer to slice of %s supported", out, um.outType)
+	}
+	decoded := reflect.MakeSlice(outValue.Elem().Type(), len(records), len(records))
+	for i, record := range records {
+		value, err := um.unmarshalRow(record, nil)
+		if err != nil {
+			return fmt.Errorf("cannot decode record %d: %w", i, err)
+		}
+		decoded.Index(i).Set(reflect.ValueOf(value))
+	}
+	outValue.Elem().Set(decoded)
+	return nil
+}
+
 // Read returns an interface{} whose runtime type is the same as the struct that
 // was used to create the Unmarshaller.
 func (um *Unmarshaller) Read() (interface{}, error) {
-	row, err := um.reader.Read()
+	if um.reader == nil {
+		return nil, fmt.Errorf("cannot read, the unmarshaller was created without reader")
+	}
+	row, err := um.readRow()
 	if err != nil {
 		return nil, err
 	}
@@ -45,7 +85,10 @@ func (um *Unmarshaller) Read() (interface{}, error) {
 
 // ReadUnmatched is same as Read(), but returns a map of the columns that didn't match a field in the struct
 func (um *Unmarshaller) ReadUnmatched() (interface{}, map[string]string, error) {
-	row, err := um.reader.Read()
+	if um.reader == nil {
+		return nil, nil, fmt.Errorf("cannot read, the unmarshaller was created without reader")
+	}
+	row, err := um.readRow()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -54,6 +97,132 @@ func (um *Unmarshaller) ReadUnmatched() (interface{}, map[string]string, error)
 	return value, unmatched, err
 }
 
+// SetTagSeparator sets the separator of the keys and options in the tags of the struct
+// type, in place of the package TagSeparator, so that unmarshallers of types tagged
+// with different separators can be used at the same time. The headers are matched to
+// the fields again, and the error of this matching is returned. The empty separator
+// restores TagSeparator.
+func (um *Unmarshaller) SetTagSeparator(sep string) error {
+	um.tagSeparator = sep
+	return validate(um, um.out, um.Headers)
+}
+
+// getStructInfo returns the struct info of t, with the tag separator of the Unmarshaller.
+func (um *Unmarshaller) getStructInfo(t reflect.Type) *structInfo {
+	if um.tagSeparator == "" {
+		return getStructInfo(t)
+	}
+	return getStructInfoWithSeparator(t, um.tagSeparator)
+}
+
+// SetColumnMapping maps the column i to the field with the struct key keys[i], or to no
+// field for "", in place of matching the headers, e.g. for a vendor whose header row
+// does not describe its columns. The columns after the last key are not mapped. A key
+// matching no field is an error, and the mapping is then left as is.
+func (um *Unmarshaller) SetColumnMapping(keys []string) error {
+	t := um.outType
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	structInfo := um.getStructInfo(t)
+	if structInfo.err != nil {
+		return structInfo.err
+	}
+	fieldInfoMap := make([]*fieldInfo, len(keys))
+	for i, key := range keys {
+		if key == "" {
+			continue
+		}
+		if fieldInfoMap[i] = getCSVFieldPosition(normalizeName(key), structInfo, 0); fieldInfoMap[i] == nil {
+			return fmt.Errorf("cannot map column %d to %s, no field has this key", i, key)
+		}
+	}
+	um.fieldInfoMap = fieldInfoMap
+	um.applyFieldDecoders()
+	um.MismatchedHeaders = nil
+	um.MismatchedStructFields = mismatchStructFields(structInfo.Fields, keys)
+	return nil
+}
+
+// SetRowRange sets Read and ReadUnmatched to return the data rows from start to end,
+// excluded, counted from 0 after the header, e.g. for each worker to decode its own
+// shard of a large file. The rows before start are still read, but not converted, and
+// io.EOF is returned once end is reached. A negative end reads to the end of the CSV.
+func (um *Unmarshaller) SetRowRange(start, end int) {
+	um.rowStart, um.rowEnd = start, end
+}
+
+// readRow reads the next row in the range set with SetRowRange.
+func (um *Unmarshaller) readRow() ([]string, error) {
+	for {
+		if um.rowEnd >= 0 && um.rowsRead >= um.rowEnd {
+			return nil, io.EOF
+		}
+		um.lastRowOffset = um.inputOffset()
+		row, err := um.reader.Read()
+		if err != nil {
+			return nil, err
+		}
+		um.rowsRead++
+		if um.rowsRead > um.rowStart {
+			return row, nil
+		}
+	}
+}
+
+// SetFieldDecoder sets the function converting the cell of the field with the structKey
+// tag, in place of the conversion by type, e.g. to parse an enum that has another cell
+// format elsewhere. The value returned must be assignable to the field, or of the same
+// kind as it, and a nil value leaves the zero value. A nil function restores the
+// conversion by type.
+func (um *Unmarshaller) SetFieldDecoder(structKey string, f func(cell string) (interface{}, error)) {
+	if um.fieldDecoders == nil {
+		um.fieldDecoders = make(map[string]func(string) (interface{}, error))
+	}
+	if f == nil {
+		delete(um.fieldDecoders, normalizeName(structKey))
+	} else {
+		um.fieldDecoders[normalizeName(structKey)] = f
+	}
+	um.applyFieldDecoders()
+}
+
+// applyFieldDecoders sets the decode function of the field infos of the columns, which
+// are copies owned by the Unmarshaller.
+func (um *Unmarshaller) applyFieldDecoders() {
+	for _, fieldInfo := range um.fieldInfoMap {
+		if fieldInfo == nil {
+			continue
+		}
+		fieldInfo.decode = nil
+		for _, key := range fieldInfo.keys {
+			if f, ok := um.fieldDecoders[key]; ok {
+				fieldInfo.decode = f
+				break
+			}
+		}
+	}
+}
+
+// LastRowOffset returns the byte offset in the input of the row returned by the last
+// call to Read or ReadUnmatched, e.g. to build an index of a large file, or -1. The
+// csv.Reader buffers its input, so the bytes read from the underlying reader tell
+// nothing about where a row starts: the offset is the one the csv.Reader reports
+// after the previous row, which needs Go 1.19, and it is -1 with older versions.
+// A comment or an empty line before the row is counted as part of it.
+func (um *Unmarshaller) LastRowOffset() int64 {
+	return um.lastRowOffset
+}
+
+// inputOffset returns the offset of the end of the last row read by the csv.Reader,
+// or -1 when it does not report it.
+func (um *Unmarshaller) inputOffset() int64 {
+	if r, ok := interface{}(um.reader).(interface{ InputOffset() int64 }); ok {
+		return r.InputOffset()
+	}
+	return -1
+}
+
 // validate ensures that a struct was used to create the Unmarshaller, and validates
 // CSV headers against the CSV tags in the struct.
 func validate(um *Unmarshaller, s interface{}, headers []string) error {
@@ -64,13 +233,26 @@ func validate(um *Unmarshaller, s interface{}, headers []string) error {
 	if err := ensureOutInnerType(concreteType); err != nil {
 		return err
 	}
-	structInfo := getStructInfo(concreteType) // Get struct info to get CSV annotations.
+	structInfo := um.getStructInfo(concreteType) // Get struct info to get CSV annotations.
+	if structInfo.err != nil {
+		return structInfo.err
+	}
 	if len(structInfo.Fields) == 0 {
 		return ErrNoStructTags
 	}
+	if err := unsupportedFields(concreteType, structInfo, false); err != nil {
+		return err
+	}
 	csvHeadersLabels := make([]*fieldInfo, len(headers)) // Used to store the corresponding header <-> position in CSV
 	headerCount := map[string]int{}
 	for i, csvColumnHeader := range headers {
+		if fieldInfo := getCSVIndexedField(i, structInfo); fieldInfo != nil {
+			csvHeadersLabels[i] = fieldInfo
+			continue
+		}
+		if isIgnoredColumn(csvColumnHeader) {
+			continue
+		}
 		curHeaderCount := headerCount[csvColumnHeader]
 		if fieldInfo := getCSVFieldPosition(csvColumnHeader, structInfo, curHeaderCount); fieldInfo != nil {
 			csvHeadersLabels[i] = fieldInfo
@@ -86,9 +268,21 @@ func validate(um *Unmarshaller, s interface{}, headers []string) error {
 			return err
 		}
 	}
+	if FailIfUnmatchedColumns || unmatchedColumnHandler != nil {
+		matched := make(map[int]*fieldInfo, len(csvHeadersLabels))
+		for i, fieldInfo := range csvHeadersLabels {
+			if fieldInfo != nil {
+				matched[i] = fieldInfo
+			}
+		}
+		if err := maybeUnmatchedColumns(headers, matched); err != nil {
+			return err
+		}
+	}
 
 	um.Headers = headers
 	um.fieldInfoMap = csvHeadersLabels
+	um.applyFieldDecoders()
 	um.MismatchedHeaders = mismatchHeaderFields(structInfo.Fields, headers)
 	um.MismatchedStructFields = mismatchStructFields(structInfo.Fields, headers)
 	um.out = s
@@ -104,12 +298,19 @@ func (um *Unmarshaller) unmarshalRow(row []string, unmatched map[string]string)
 		isPointer = true
 		concreteOutType = concreteOutType.Elem()
 	}
+	if j := invalidUTF8Cell(row); j >= 0 {
+		return nil, &ConversionError{Column: headerAt(um.Headers, j), Value: row[j], Err: ErrInvalidUTF8}
+	}
 	outValue := createNewOutInner(isPointer, concreteOutType)
 	for j, csvColumnContent := range row {
 		if j < len(um.fieldInfoMap) && um.fieldInfoMap[j] != nil {
 			fieldInfo := um.fieldInfoMap[j]
-			if err := setInnerField(&outValue, isPointer, fieldInfo.IndexChain, csvColumnContent, fieldInfo.omitEmpty); err != nil { // Set field of struct
-				return nil, fmt.Errorf("cannot assign field at %v to %s through index chain %v: %v", j, outValue.Type(), fieldInfo.IndexChain, err)
+			if err := setInnerField(&outValue, isPointer, fieldInfo.IndexChain, csvColumnContent, fieldInfo); err != nil { // Set field of struct
+				convErr := &ConversionError{Column: um.Headers[j], Value: csvColumnContent, Err: err}
+				if isClampWarning(convErr) {
+					continue
+				}
+				return nil, fmt.Errorf("cannot assign field at %v to %s through index chain %v: %w", j, outValue.Type(), fieldInfo.IndexChain, convErr)
 			}
 		} else if unmatched != nil {
 			unmatched[um.Headers[j]] = csvColumnContent
@@ -2,6 +2,7 @@ package gocsv
 
 import (
 	"encoding/csv"
+	"fmt"
 	"io"
 	"strings"
 	"testing"
@@ -104,3 +105,196 @@ c,d,e
 		t.Fatalf("Unepxected result from Read(): (%#v, %#v)", obj, err)
 	}
 }
+
+func TestUnmarshallerDecodeRecords(t *testing.T) {
+	um, err := NewUnmarshallerWithHeaders([]string{"BAR", "foo"}, Sample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var samples []Sample
+	if err := um.DecodeRecords([][]string{{"1", "a"}, {"2", "b"}}, &samples); err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 || samples[0].Foo != "a" || samples[1].Bar != 2 {
+		t.Fatalf("unexpected first chunk %v", samples)
+	}
+	if err := um.DecodeRecords([][]string{{"3", "c"}}, &samples); err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 1 || samples[0].Foo != "c" || samples[0].Bar != 3 {
+		t.Fatalf("unexpected second chunk %v", samples)
+	}
+
+	if err := um.DecodeRecords([][]string{{"x", "d"}}, &samples); err == nil {
+		t.Fatal("expected a conversion error")
+	}
+	var ptrs []*Sample
+	if err := um.DecodeRecords([][]string{{"1", "a"}}, &ptrs); err == nil {
+		t.Fatal("expected an error for a slice of another type")
+	}
+	if _, err := um.Read(); err == nil {
+		t.Fatal("expected an error from Read without reader")
+	}
+}
+
+func TestUnmarshallerLastRowOffset(t *testing.T) {
+	type sample struct {
+		FieldA string `csv:"field_a"`
+		FieldB string `csv:"field_b"`
+	}
+	const csvContents = "field_a,field_b\na,b\n\"multi\nline\",d\nlast,f\n"
+
+	um, err := NewUnmarshaller(csv.NewReader(strings.NewReader(csvContents)), sample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if um.LastRowOffset() != -1 {
+		t.Errorf("expected -1 before the first read, got %d", um.LastRowOffset())
+	}
+	for _, expected := range []string{"a,b\n", "\"multi\nline\",d\n", "last,f\n"} {
+		if _, err := um.Read(); err != nil {
+			t.Fatal(err)
+		}
+		offset := um.LastRowOffset()
+		if !strings.HasPrefix(csvContents[offset:], expected) {
+			t.Errorf("expected the row %q at offset %d, got %q", expected, offset, csvContents[offset:])
+		}
+	}
+}
+
+func TestUnmarshallerSetFieldDecoder(t *testing.T) {
+	type level int
+	type sample struct {
+		Level  level  `csv:"level"`
+		Other  level  `csv:"other"`
+		Parent *level `csv:"parent"`
+	}
+	levels := map[string]int{"low": 1, "high": 2}
+	decodeLevel := func(cell string) (interface{}, error) {
+		l, ok := levels[cell]
+		if !ok {
+			return nil, fmt.Errorf("unknown level %q", cell)
+		}
+		return l, nil
+	}
+
+	reader := csv.NewReader(strings.NewReader("level,other,parent\nhigh,3,low\nmedium,1,high\n"))
+	um, err := NewUnmarshaller(reader, sample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	um.SetFieldDecoder("level", decodeLevel)
+	um.SetFieldDecoder("parent", func(cell string) (interface{}, error) {
+		l, err := decodeLevel(cell)
+		if err != nil {
+			return nil, err
+		}
+		return level(l.(int)), nil
+	})
+	obj, err := um.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := obj.(sample); s.Level != 2 || s.Other != 3 || *s.Parent != 1 {
+		t.Errorf("unexpected sample %+v", s)
+	}
+	if _, err := um.Read(); err == nil || !strings.Contains(err.Error(), "unknown level") {
+		t.Errorf("expected an unknown level error, got %v", err)
+	}
+
+	um.SetFieldDecoder("level", func(string) (interface{}, error) { return "high", nil })
+	if err := um.DecodeRecords([][]string{{"high", "1", "low"}}, &[]sample{}); err == nil {
+		t.Error("expected an error for a string decoded into an int field")
+	}
+}
+
+func TestUnmarshallerSetRowRange(t *testing.T) {
+	type sample struct {
+		N int `csv:"n"`
+	}
+	in := "n\n0\n1\nnot a number\n3\n4\n"
+	um, err := NewUnmarshaller(csv.NewReader(strings.NewReader(in)), sample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	um.SetRowRange(3, 5)
+	var got []int
+	for {
+		v, err := um.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v.(sample).N)
+	}
+	if fmt.Sprint(got) != "[3 4]" {
+		t.Errorf("expected rows 3 and 4, got %v", got)
+	}
+
+	um, err = NewUnmarshaller(csv.NewReader(strings.NewReader(in)), sample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	um.SetRowRange(1, 2)
+	if v, err := um.Read(); err != nil || v.(sample).N != 1 {
+		t.Fatalf("expected row 1, got %v, %v", v, err)
+	}
+	if _, err := um.Read(); err != io.EOF {
+		t.Errorf("expected io.EOF after the range, got %v", err)
+	}
+}
+
+func TestUnmarshallerSetTagSeparator(t *testing.T) {
+	type sample struct {
+		Name string  `csv:"name;nom"`
+		Rate float64 `csv:"rate;percent"`
+	}
+	um, err := NewUnmarshaller(csv.NewReader(strings.NewReader("nom,rate\na,7%\n")), sample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := um.SetTagSeparator(";"); err != nil {
+		t.Fatal(err)
+	}
+	v, err := um.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := v.(sample); s.Name != "a" || s.Rate != 0.07 {
+		t.Errorf("unexpected value %+v", s)
+	}
+}
+
+func TestUnmarshallerSetColumnMapping(t *testing.T) {
+	type sample struct {
+		ID   int    `csv:"id"`
+		Name string `csv:"name"`
+		City string `csv:"city"`
+	}
+	in := "name,id,junk\n7,alice,x\n"
+	um, err := NewUnmarshaller(csv.NewReader(strings.NewReader(in)), sample{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := um.SetColumnMapping([]string{"id", "", "missing"}); err == nil {
+		t.Error("expected an error for a key matching no field")
+	}
+	if err := um.SetColumnMapping([]string{"id", "name", ""}); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(um.MismatchedStructFields) != "[city]" {
+		t.Errorf("unexpected mismatched struct fields %v", um.MismatchedStructFields)
+	}
+	v, unmatched, err := um.ReadUnmatched()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := v.(sample); s.ID != 7 || s.Name != "alice" || s.City != "" {
+		t.Errorf("unexpected value %+v", s)
+	}
+	if unmatched["junk"] != "x" || len(unmatched) != 1 {
+		t.Errorf("unexpected unmatched columns %v", unmatched)
+	}
+}